@@ -1,11 +1,16 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 	"wizscan/pkg/logger"
 	"wizscan/pkg/utility"
@@ -13,6 +18,7 @@ import (
 	"wizscan/pkg/wizapi"
 	"wizscan/pkg/wizcli"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -25,6 +31,13 @@ func main() {
 		logger.Log.Errorf("Failed to parse arguments: %v", err)
 		os.Exit(1) // Exit the program with a non-zero status indicating failure
 	}
+	if args.ShowConfig {
+		if err := utility.ShowConfig(os.Stdout, args); err != nil {
+			logger.Log.Errorf("Failed to show config: %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if args.Uninstall {
 		// Call your uninstallation logic here
 		logger.Log.Info("Performing uninstallation...")
@@ -44,31 +57,184 @@ func main() {
 		os.Exit(0)
 	}
 
-	apiClient := wizapi.NewWizAPI(args.WizClientID, args.WizClientSecret, args.WizAuthURL, args.WizQueryURL)
+	// Route all outbound traffic, including wizcli's, through the configured proxy and CAs
+	cleanupNetwork, err := utility.ConfigureNetwork(utility.NetworkConfig{
+		ProxyURL: args.ProxyURL,
+		NoProxy:  args.NoProxy,
+		CABundle: args.CABundle,
+	})
+	if err != nil {
+		logger.Log.Errorf("Invalid network configuration: %v", err)
+		os.Exit(1)
+	}
+
+	// Failures past this point set the exit code and return, so the deferred cleanup still runs
+	// before the process exits
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+	defer cleanupNetwork()
+
+	// Load suppression rules up front so a bad file fails the run before any scanning
+	var suppressionRules []vulnerability.SuppressionRule
+	if args.SuppressionFile != "" {
+		suppressionRules, err = vulnerability.LoadSuppressionRules(args.SuppressionFile, time.Now())
+		if err != nil {
+			logger.Log.Errorf("Invalid suppression file: %v", err)
+			exitCode = 1
+			return
+		}
+	}
+
+	riskWeights, err := vulnerability.ParseRiskWeights(args.RiskWeights)
+	if err != nil {
+		logger.Log.Errorf("Invalid risk weights: %v", err)
+		exitCode = 1
+		return
+	}
+	severityMap, err := vulnerability.ParseSeverityMap(args.SeverityMap)
+	if err != nil {
+		logger.Log.Errorf("Invalid severity map: %v", err)
+		exitCode = 1
+		return
+	}
+	findingTemplates, err := vulnerability.LoadFindingTemplates(args.DescriptionTmpl, args.RemediationTmpl)
+	if err != nil {
+		logger.Log.Errorf("Invalid finding template: %v", err)
+		exitCode = 1
+		return
+	}
+	compareOptions := vulnerability.Options{
+		FindingIDScheme: args.FindingIDScheme,
+		RiskWeights:     riskWeights,
+		SeverityMap:     severityMap,
+
+		DropInconsistent: args.DropInconsistent,
+	}
+
+	// Stop cleanly on Ctrl+C or a service stop, and give up once the overall timeout is reached
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+
+	apiClient := wizapi.NewWizAPI(ctx, args.WizClientID, args.WizClientSecret, args.WizAuthURL, args.WizQueryURL, args.RequestTimeout)
 	if apiClient == nil {
 		logger.Log.Error("Failed to initialize API client")
 		return
 	} else {
 		logger.Log.Debugf("API Client: %+v\n", apiClient)
 	}
+	apiClient.MaxRetries = args.MaxRetries
+	apiClient.TransferTimeout = args.TransferTimeout
 
-	// Retrieve the resource ID
-	resourceId, err := apiClient.GetResourceID(args.ScanCloudType, args.ScanProviderID)
+	hostname, err := os.Hostname()
 	if err != nil {
-		logger.Log.Error(err)
+		logger.Log.Warnf("Failed to read hostname: %v", err)
+	}
+	privateIPs, err := utility.PrivateIPAddresses()
+	if err != nil {
+		logger.Log.Warnf("Failed to read private IP addresses: %v", err)
+	}
+
+	// Render the data source ID before scanning, so a typo in the template does not waste a scan
+	dataSourceID, err := vulnerability.RenderDataSourceID(args.DataSourceID, vulnerability.DataSourceIDData{
+		Hostname:       hostname,
+		RunID:          uuid.NewString(),
+		SubscriptionID: args.ScanSubscriptionID,
+		ProviderID:     args.ScanProviderID,
+		CloudType:      args.ScanCloudType,
+	})
+	if err != nil {
+		logger.Log.Errorf("Invalid data source ID: %v", err)
+		exitCode = 1
 		return
 	}
+	if strings.Contains(args.DataSourceID, ".RunID") {
+		logger.Log.Warn("-dataSourceId uses RunID, so every run uploads to a new data source and findings uploaded by earlier runs are never closed in Wiz")
+	}
 
-	logger.Log.Debugf("Matched Resource ID: %s", resourceId)
+	lookup := wizapi.ResourceLookup{
+		CloudType:  args.ScanCloudType,
+		ProviderID: args.ScanProviderID,
+		Name:       args.ResourceName,
+		Hostname:   hostname,
+		PrivateIPs: privateIPs,
+		Types:      utility.SplitList(args.ResourceTypes),
+		ProjectID:  args.ProjectID,
+		Strategies: utility.SplitList(args.ResourceMatch),
+	}
+	queryOptions := wizapi.VulnerabilityQueryOptions{
+		PageSize:    args.PageSize,
+		Status:      utility.SplitList(args.KnownStatus),
+		DataSources: utility.SplitList(args.KnownDataSource),
+	}
+	if queryOptions.SkipsIgnored() {
+		logger.Log.Warn("-knownStatus leaves out IGNORED, so vulnerabilities ignored in Wiz are not recognized and are uploaded again")
+	}
 
-	// response == known vulnerabilities
-	response, err := wizapi.FetchAllVulnerabilities(apiClient, resourceId)
-	if err != nil {
+	var knownCache *wizapi.KnownCache
+	if !args.NoCache || args.ClearCache {
+		cacheDir := args.CacheDir
+		if cacheDir == "" {
+			if cacheDir, err = wizapi.DefaultCacheDir(); err != nil {
+				logger.Log.Warnf("Caching disabled: %v", err)
+			}
+		}
+		if cacheDir != "" {
+			knownCache = &wizapi.KnownCache{Dir: cacheDir}
+		}
+	}
+	if args.ClearCache && knownCache != nil {
+		removed, err := knownCache.Clear()
+		if err != nil {
+			logger.Log.Errorf("Failed to clear cache: %v", err)
+			exitCode = 1
+			return
+		}
+		logger.Log.Infof("Cleared %d cache entries from %s", removed, knownCache.Dir)
+	}
+	if args.NoCache {
+		knownCache = nil
+	}
+
+	// Retrieve the resource ID and the known vulnerabilities, falling back to the host's names and
+	// addresses when the provider ID does not match
+	cacheKey := wizapi.CacheKey(args.WizQueryURL, lookup, queryOptions)
+	known, err := fetchKnown(ctx, apiClient, knownCache, cacheKey, args.CacheTTL, lookup, queryOptions)
+	var ambiguous *wizapi.AmbiguousResourceError
+	if errors.As(err, &ambiguous) {
+		logger.Log.Errorf("%v\nNarrow the search with -resourceTypes, -projectId, -resourceMatch or -resourceName", err)
+		return
+	} else if known == nil {
+		logger.Log.Error(err)
+		return
+	} else if err != nil {
+		// Comparing against no known vulnerabilities would upload every finding as new
 		logger.Log.Errorf("Error fetching vulnerabilities: %v", err)
-		logger.Log.Debug("Vulnerability Query Response: ", response)
+		exitCode = 1
+		return
 	}
+	resourceId := known.ResourceID
+	logger.Log.Debugf("Matched Resource ID: %s", resourceId)
 
-	var assetVulns vulnerability.Asset
+	// A host found by name or address is uploaded under the external ID Wiz knows it by, since
+	// the configured provider ID is the one that did not match
+	providerID := args.ScanProviderID
+	if known.ExternalID != "" && known.ExternalID != providerID {
+		logger.Log.Infof("Uploading findings for external ID %s of the matched resource instead of -scanProviderId %q", known.ExternalID, providerID)
+		providerID = known.ExternalID
+	}
+	logger.Log.Infof("Known vulnerabilities from %s", known.Source)
+
+	// response == known vulnerabilities
+	response := known.Vulnerabilities
 
 	/*
 		jsonResponseBytes, err := json.MarshalIndent(response, "", "    ")
@@ -86,7 +252,7 @@ func main() {
 	*/
 
 	// Initialize and authenticate wizcli
-	cleanup, wizCliPath, err := wizcli.InitializeAndAuthenticate(args.WizClientID, args.WizClientSecret)
+	cleanup, wizCliPath, err := wizcli.InitializeAndAuthenticate(ctx, args.WizClientID, args.WizClientSecret, args.TransferTimeout)
 	if err != nil {
 		logger.Log.Errorf("Initialization and authentication failed: %v", err)
 		return
@@ -109,6 +275,8 @@ func main() {
 	//directories = []string{"E:\\"}
 
 	logger.Log.Info("Initiating directory scan")
+	scanStart := time.Now()
+	var scanCreatedAt time.Time
 	for _, drive := range directories {
 		mountedPath := ""
 		shadowCopyID := ""
@@ -124,12 +292,27 @@ func main() {
 		if mountedPath == "" {
 			mountedPath = drive
 		}
-		scanResult, err := wizcli.ScanDirectory(wizCliPath, mountedPath)
+		scanResult, err := wizcli.ScanDirectory(ctx, wizCliPath, mountedPath)
+
+		// Remove the VSS snapshot and link
+		if runtime.GOOS == "windows" {
+			if err := utility.RemoveVSSSnapshot(mountedPath, shadowCopyID); err != nil {
+				logger.Log.Errorf("Failed to remove mount and VSS snapshot for drive %s: %v", drive, err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
 			logger.Log.Errorf("Failed to scan %s: %v", mountedPath, err)
 			continue
 		}
 
+		if !scanResult.CreatedAt.IsZero() && (scanCreatedAt.IsZero() || scanResult.CreatedAt.Before(scanCreatedAt)) {
+			scanCreatedAt = scanResult.CreatedAt
+		}
+
 		// Prepend the Drive to the Library path to represent actual full path
 		for i, lib := range scanResult.Result.Libraries {
 			if runtime.GOOS == "windows" {
@@ -138,18 +321,30 @@ func main() {
 			}
 			scanResult.Result.Libraries[i].Path = drive + lib.Path
 		}
+		// Application paths get the same treatment, so duplicates across sections share a path
+		for _, app := range scanResult.Result.Applications {
+			for j, vuln := range app.Vulnerabilities {
+				appPath, ok := vuln.Path.(string)
+				if !ok || appPath == "" {
+					continue
+				}
+				if runtime.GOOS == "windows" {
+					appPath = strings.ReplaceAll(appPath, "/", "\\")
+					appPath = strings.TrimPrefix(appPath, "\\")
+				}
+				app.Vulnerabilities[j].Path = drive + appPath
+			}
+		}
 
 		// Aggregate results
 		aggregatedResults.Libraries = append(aggregatedResults.Libraries, scanResult.Result.Libraries...)
 		aggregatedResults.Applications = append(aggregatedResults.Applications, scanResult.Result.Applications...)
-		// Remove the VSS snapshot and link
-		if runtime.GOOS == "windows" {
-
-			if err := utility.RemoveVSSSnapshot(mountedPath, shadowCopyID); err != nil {
-				logger.Log.Errorf("Failed to remove mount and VSS snapshot for drive %s: %v", drive, err)
-			}
-		}
+	}
 
+	// A partial scan must not be uploaded, since the missing findings would be resolved in Wiz
+	if ctx.Err() != nil {
+		logger.Log.Errorf("Scan interrupted: %v", context.Cause(ctx))
+		return
 	}
 	/*
 		jsonBytes, err := json.MarshalIndent(aggregatedResults, "", "    ")
@@ -165,44 +360,233 @@ func main() {
 		}
 	*/
 
-	assetVulns, err = vulnerability.CompareVulnerabilities(aggregatedResults, response, args.ScanProviderID)
+	assetVulns, decisions, err := vulnerability.CompareVulnerabilities(aggregatedResults, response, args.ScanProviderID, compareOptions)
 	if err != nil {
 		fmt.Printf("Error in CompareVulnerabilities: %s\n", err)
 		return
 	}
 
-	var vulnPayloadJSON []byte // Use a byte slice to hold JSON data
+	assetVulns.AssetIdentifier.CloudPlatform = args.ScanCloudType
+	assetVulns.AssetIdentifier.ProviderId = providerID
+
+	// The same component can be reached through several scan roots or result sections
+	if merged := vulnerability.DeduplicateFindings(&assetVulns); len(merged) > 0 {
+		logger.Log.Infof("Collapsed %d duplicate findings", len(merged))
+		vulnerability.MarkMerged(decisions, merged)
+	}
+
+	// Descriptions and remediation are rendered once the merged locations are known
+	if err := vulnerability.RenderFindings(&assetVulns, findingTemplates); err != nil {
+		logger.Log.Errorf("Failed to render findings: %v", err)
+		return
+	}
+
+	suppressed := vulnerability.ApplySuppressions(&assetVulns, suppressionRules)
+	if len(suppressed) > 0 {
+		logger.Log.Infof("Suppressed %d findings", len(suppressed))
+		vulnerability.MarkRemoved(decisions, suppressed, vulnerability.VerdictSuppress, "Covered by a suppression rule")
+	}
+
+	filtered := vulnerability.FilterByRiskScore(&assetVulns, args.MinRiskScore)
+	if len(filtered) > 0 {
+		logger.Log.Infof("Filtered %d findings with a risk score below %.1f", len(filtered), args.MinRiskScore)
+		vulnerability.MarkRemoved(decisions, filtered, vulnerability.VerdictFilter, fmt.Sprintf("Risk score below %.1f", args.MinRiskScore))
+	}
 
-	if len(assetVulns.VulnerabilityFindings) > 0 {
-		assetVulns.AssetIdentifier.CloudPlatform = args.ScanCloudType
-		assetVulns.AssetIdentifier.ProviderId = args.ScanProviderID
-		vulnPayload := vulnerability.IntegrationData{
-			IntegrationId: "e7ddcf48-a2f3-fd39-89f4-b27c4efca17c", // Set an integration ID
-			DataSources:   []vulnerability.DataSource{},           // Initialize an empty slice of DataSources
+	// Previously uploaded findings missing from the final payload, whether remediated, suppressed
+	// or filtered out, are closed in Wiz by the uploaded snapshot
+	resolvedVulns := vulnerability.FindResolvedVulnerabilities(assetVulns, response)
+	for _, kv := range resolvedVulns {
+		logger.Log.Infof("Closing: %s in %s (ID: %s)", kv.Name, kv.DetailedName, kv.ID)
+	}
+
+	analysisDate := time.Now()
+	switch args.AnalysisDate {
+	case "scanStart":
+		analysisDate = scanStart
+	case "scanResult":
+		if scanCreatedAt.IsZero() {
+			logger.Log.Warn("No scan result carried a creation date, using the current time as the analysis date")
+		} else {
+			analysisDate = scanCreatedAt
 		}
-		// Create a DataSource and add assetVulns to it
-		dataSource := vulnerability.DataSource{
-			Id:           args.ScanSubscriptionID,
-			AnalysisDate: time.Now(),                        // Set current time as the analysis date
-			Assets:       []vulnerability.Asset{assetVulns}, // Add assetVulns here
+	}
+
+	vulnPayload := vulnerability.IntegrationData{
+		IntegrationId: args.IntegrationID,
+		DataSources:   []vulnerability.DataSource{}, // Initialize an empty slice of DataSources
+	}
+	// Create a DataSource and add assetVulns to it
+	dataSource := vulnerability.DataSource{
+		Id:           dataSourceID,
+		AnalysisDate: analysisDate,
+		Assets:       []vulnerability.Asset{assetVulns}, // Add assetVulns here
+	}
+
+	vulnPayload.DataSources = append(vulnPayload.DataSources, dataSource)
+
+	// Catch malformed payloads here rather than in the system activity after the upload
+	if err := vulnerability.ValidateIntegrationData(vulnPayload, time.Now()); err != nil {
+		logger.Log.Errorf("%v", err)
+		exitCode = 1
+		return
+	}
+
+	// Large hosts are uploaded in several files so ingestion does not have to take one huge payload
+	// Each upload is a part with its own data source; parts left over from an earlier run are sent
+	// empty so their findings are closed
+	partsKey := wizapi.PartsKey(dataSourceID, providerID)
+	previousParts := 0
+	if knownCache != nil {
+		if previousParts, err = knownCache.LoadParts(partsKey); err != nil {
+			logger.Log.Warnf("Ignoring cached number of upload parts: %v", err)
+		}
+	}
+	chunks, dataParts, err := vulnerability.SplitIntegrationData(vulnPayload, args.MaxFindingsPerUpload, args.MaxUploadBytes, previousParts)
+	if err != nil {
+		logger.Log.Errorf("Error marshaling payload: %v", err)
+		return
+	}
+	if len(chunks) > 1 {
+		logger.Log.Infof("Payload split into %d uploads", len(chunks))
+		if knownCache == nil {
+			logger.Log.Warn("Without the cache, a later run uploading fewer parts leaves the findings of the extra parts open")
+		}
+	}
+
+	if args.DryRun {
+		// Stdout may carry the payload, so reports go to stderr unless the payload goes to a file
+		reportOutput := os.Stderr
+		if args.Output != "-" {
+			reportOutput = os.Stdout
+		}
+		explainFormat := args.Explain
+		if explainFormat == "" {
+			explainFormat = "table"
 		}
+		if err := writeExplain(reportOutput, explainFormat, decisions); err != nil {
+			logger.Log.Errorf("Error writing explain output: %v", err)
+		}
+		summary := vulnerability.NewSummary(assetVulns, decisions, len(resolvedVulns))
+		summary.KnownSource = known.Source
+		if err := summary.Write(reportOutput); err != nil {
+			logger.Log.Errorf("Error writing summary: %v", err)
+		}
+		if err := writePayloads(args.Output, chunks); err != nil {
+			logger.Log.Errorf("Error writing payload: %v", err)
+			exitCode = 1
+			return
+		}
+		logger.Log.Info("Dry run complete, nothing was uploaded")
+		return
+	}
+
+	if err := writeExplain(os.Stdout, args.Explain, decisions); err != nil {
+		logger.Log.Errorf("Error writing explain output: %v", err)
+	}
 
-		vulnPayload.DataSources = append(vulnPayload.DataSources, dataSource)
+	if len(assetVulns.VulnerabilityFindings) == 0 && len(resolvedVulns) == 0 {
+		logger.Log.Infof("No findings to upload and no previously uploaded findings to close")
+		return // Exit the program gracefully
+	}
 
-		vulnPayloadJSON, err = json.MarshalIndent(vulnPayload, "", "\t")
+	var totals wizapi.IngestionTotals
+	for i, chunk := range chunks {
+		result, err := publishChunk(ctx, apiClient, chunk)
 		if err != nil {
-			fmt.Println("Error marshaling assetVulns to JSON:", err)
+			logger.Log.Errorf("Error publishing upload %d of %d, stopping: %v", i+1, len(chunks), err)
+			exitCode = 1
 			return
 		}
-	} else {
-		logger.Log.Infof("No new vulnerabilities found")
-		return // Exit the program gracefully
+		logger.Log.Infof("Upload %d of %d: %s, %d of %d findings handled", i+1, len(chunks), result.Status, result.Findings.Handled, result.Findings.Incoming)
+		totals.Add(result)
+	}
+	logger.Log.Infof("Ingested %d uploads: %v; findings handled %d of %d, unresolved assets %d",
+		totals.Uploads, totals.ByStatus, totals.Findings.Handled, totals.Findings.Incoming, totals.UnresolvedAssets)
+
+	// The upload changed the known vulnerabilities, and the next run must see the parts holding
+	// data; padding parts are empty now and need no closing again
+	if knownCache != nil {
+		if err := knownCache.Invalidate(cacheKey); err != nil {
+			logger.Log.Warnf("Failed to update cache: %v", err)
+		}
+		if err := knownCache.SaveParts(partsKey, dataParts); err != nil {
+			logger.Log.Warnf("Failed to update cache: %v", err)
+		}
+	}
+}
+
+// knownState is what Wiz knows about the host and where it came from.
+type knownState struct {
+	ResourceID      string
+	ExternalID      string // External ID of the matched resource, empty if Wiz has none
+	Vulnerabilities []wizapi.VulnerabilityNode
+	Source          string // Reported in the run summary
+}
+
+// fetchKnown resolves the host's resource ID and fetches its known vulnerabilities. A cache entry
+// younger than ttl is used instead of the Wiz API, and one of any age when the API fails for a
+// reason other than the host not being found, unless an upload has since dropped its vulnerabilities.
+// Only a failed resource lookup returns a nil state; when only the vulnerabilities could not be
+// fetched, the state has none and the error is returned.
+func fetchKnown(ctx context.Context, apiClient *wizapi.WizAPI, cache *wizapi.KnownCache, key string, ttl time.Duration, lookup wizapi.ResourceLookup, options wizapi.VulnerabilityQueryOptions) (*knownState, error) {
+	var cached *wizapi.CachedKnown
+	if cache != nil {
+		var err error
+		if cached, err = cache.Load(key); err != nil {
+			logger.Log.Warnf("Ignoring cache: %v", err)
+		}
+	}
+	fromCache := func() *knownState {
+		age := cached.Age(time.Now()).Round(time.Second)
+		return &knownState{
+			ResourceID:      cached.ResourceID,
+			ExternalID:      cached.ExternalID,
+			Vulnerabilities: cached.Vulnerabilities,
+			Source:          fmt.Sprintf("cache, fetched %s ago", age),
+		}
+	}
+
+	if cached != nil && cached.HasVulnerabilities() && cached.Age(time.Now()) < ttl {
+		logger.Log.Infof("Using cached resource ID and %d known vulnerabilities fetched at %s", len(cached.Vulnerabilities), cached.FetchedAt.Format(time.RFC3339))
+		return fromCache(), nil
+	}
+
+	resource, err := apiClient.GetResourceID(ctx, lookup)
+	if err != nil {
+		var ambiguous *wizapi.AmbiguousResourceError
+		if cached == nil || !cached.HasVulnerabilities() || ctx.Err() != nil || errors.Is(err, wizapi.ErrNoResourceMatch) || errors.As(err, &ambiguous) {
+			return nil, err
+		}
+		logger.Log.Warnf("Wiz API unavailable, using cached data fetched at %s: %v", cached.FetchedAt.Format(time.RFC3339), err)
+		return fromCache(), nil
+	}
+	resourceID := resource.ID
+
+	fetchedAt := time.Now()
+	vulnerabilities, err := wizapi.FetchAllVulnerabilities(ctx, apiClient, resourceID, options)
+	if err != nil {
+		if cached != nil && cached.HasVulnerabilities() && cached.ResourceID == resourceID && ctx.Err() == nil {
+			logger.Log.Warnf("Wiz API unavailable, using cached vulnerabilities fetched at %s: %v", cached.FetchedAt.Format(time.RFC3339), err)
+			return fromCache(), nil
+		}
+		return &knownState{ResourceID: resourceID, ExternalID: resource.ExternalID, Source: "none, the Wiz API query failed"}, err
+	}
+
+	if cache != nil {
+		entry := &wizapi.CachedKnown{Key: key, ResourceID: resourceID, ExternalID: resource.ExternalID, Vulnerabilities: vulnerabilities, FetchedAt: fetchedAt}
+		if err := cache.Save(entry); err != nil {
+			logger.Log.Warnf("Failed to update cache: %v", err)
+		}
 	}
+	return &knownState{ResourceID: resourceID, ExternalID: resource.ExternalID, Vulnerabilities: vulnerabilities, Source: "Wiz API"}, nil
+}
 
+// publishChunk uploads one payload through a temporary file and waits for it to be ingested.
+func publishChunk(ctx context.Context, apiClient *wizapi.WizAPI, payload []byte) (*wizapi.UploadResult, error) {
 	file, err := utility.CreateTempFile()
 	if err != nil {
-		logger.Log.Errorln("Error creating temp file:", err)
-		return
+		return nil, fmt.Errorf("error creating temp file: %w", err)
 	}
 
 	defer func() {
@@ -217,14 +601,50 @@ func main() {
 
 	logger.Log.Debugln("Temporary file created:", file.Name())
 
-	_, err = file.Write(vulnPayloadJSON)
-	if err != nil {
-		logger.Log.Errorln("Error writing JSON to temp file:", err)
-		return
+	if _, err := file.Write(payload); err != nil {
+		return nil, fmt.Errorf("error writing JSON to temp file: %w", err)
 	}
 
-	if err := apiClient.PublishVulns(file.Name()); err != nil {
-		logger.Log.Errorln("Error publishing vulnerabilities:", err)
+	return apiClient.PublishVulns(ctx, file.Name())
+}
+
+// writeExplain prints the comparison decisions in the requested format, if any.
+func writeExplain(w io.Writer, format string, decisions []vulnerability.Decision) error {
+	switch format {
+	case "table":
+		return vulnerability.WriteDecisionsTable(w, decisions)
+	case "json":
+		return vulnerability.WriteDecisionsJSON(w, decisions)
+	default:
+		return nil
+	}
+}
+
+// writePayloads writes the enrichment payloads to a file, or to stdout when the path is "-".
+// When the payload was split, each chunk goes to its own file numbered before the extension.
+func writePayloads(path string, payloads [][]byte) error {
+	for i, payload := range payloads {
+		target := path
+		if len(payloads) > 1 && path != "-" {
+			ext := filepath.Ext(path)
+			target = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), i+1, ext)
+		}
+		if err := writePayload(target, payload); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+// writePayload writes the enrichment payload to a file, or to stdout when the path is "-".
+func writePayload(path string, payload []byte) error {
+	if path == "-" {
+		_, err := fmt.Fprintln(os.Stdout, string(payload))
+		return err
+	}
+	if err := os.WriteFile(path, payload, 0600); err != nil {
+		return err
+	}
+	logger.Log.Infof("Payload written to %s", path)
+	return nil
 }
@@ -6,23 +6,81 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 	"wizscan/pkg/logger"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
+// DefaultIntegrationID is the Wiz integration wizscan uploads under unless another one is configured
+const DefaultIntegrationID = "e7ddcf48-a2f3-fd39-89f4-b27c4efca17c"
+
+// maxPageSize is the largest page the Wiz API serves
+const maxPageSize = 500
+
+// enumPattern matches Wiz enum values such as the entity type VIRTUAL_MACHINE
+var enumPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// findingStatuses are the vulnerability finding statuses of the Wiz API, see wizapi.FindingStatuses
+var findingStatuses = []string{"OPEN", "RESOLVED", "IGNORED"}
+
+// resourceStrategies are the strategies wizapi.GetResourceID knows
+var resourceStrategies = []string{"externalId", "name", "hostname", "privateIp"}
+
 type Arguments struct {
-	WizClientID        string `json:"wizClientId"`
-	WizClientSecret    string `json:"wizClientSecret"`
-	WizQueryURL        string `json:"wizQueryUrl"`
-	WizAuthURL         string `json:"wizAuthUrl"`
-	ScanSubscriptionID string `json:"scanSubscriptionId"`
-	ScanCloudType      string `json:"scanCloudType"`
-	ScanProviderID     string `json:"scanProviderId"`
-	Save               bool   `json:"save"`
-	Install            bool   `json:"install"`
-	Uninstall          bool   `json:"uninstall"`
+	WizClientID          string        `json:"wizClientId"`
+	WizClientSecret      string        `json:"wizClientSecret"`
+	WizQueryURL          string        `json:"wizQueryUrl"`
+	WizAuthURL           string        `json:"wizAuthUrl"`
+	ScanSubscriptionID   string        `json:"scanSubscriptionId"`
+	ScanCloudType        string        `json:"scanCloudType"`
+	ScanProviderID       string        `json:"scanProviderId"`
+	ResourceTypes        string        `json:"resourceTypes"`
+	ResourceMatch        string        `json:"resourceMatch"`
+	ResourceName         string        `json:"resourceName"`
+	ProjectID            string        `json:"projectId"`
+	PageSize             int           `json:"pageSize"`
+	KnownStatus          string        `json:"knownStatus"`
+	KnownDataSource      string        `json:"knownDataSource"`
+	CacheDir             string        `json:"cacheDir"`
+	CacheTTL             time.Duration `json:"cacheTtl"`
+	NoCache              bool          `json:"noCache"`
+	ClearCache           bool          `json:"-"`
+	IntegrationID        string        `json:"integrationId"`
+	DataSourceID         string        `json:"dataSourceId"`
+	AnalysisDate         string        `json:"analysisDate"`
+	FindingIDScheme      string        `json:"findingIdScheme"`
+	SuppressionFile      string        `json:"suppressionFile"`
+	RiskWeights          string        `json:"riskWeights"`
+	SeverityMap          string        `json:"severityMap"`
+	DescriptionTmpl      string        `json:"descriptionTemplate"`
+	RemediationTmpl      string        `json:"remediationTemplate"`
+	MinRiskScore         float64       `json:"minRiskScore"`
+	DropInconsistent     bool          `json:"dropInconsistent"`
+	MaxFindingsPerUpload int           `json:"maxFindingsPerUpload"`
+	MaxUploadBytes       int           `json:"maxUploadBytes"`
+	MaxRetries           int           `json:"maxRetries"`
+	RequestTimeout       time.Duration `json:"requestTimeout"`
+	TransferTimeout      time.Duration `json:"transferTimeout"`
+	Timeout              time.Duration `json:"timeout"`
+	ProxyURL             string        `json:"proxyUrl"`
+	NoProxy              string        `json:"noProxy"`
+	CABundle             string        `json:"caBundle"`
+	Explain              string        `json:"-"`
+	DryRun               bool          `json:"-"`
+	Output               string        `json:"-"`
+	ShowConfig           bool          `json:"-"`
+	Save                 bool          `json:"save"`
+	Install              bool          `json:"install"`
+	Uninstall            bool          `json:"uninstall"`
 }
 
 func saveConfig(config *Arguments, filePath string) error {
@@ -49,6 +107,23 @@ func saveConfig(config *Arguments, filePath string) error {
 	return nil
 }
 
+// ShowConfig prints the effective configuration as JSON with the client secret and proxy password redacted.
+func ShowConfig(w io.Writer, args *Arguments) error {
+	redacted := *args
+	if redacted.WizClientSecret != "" {
+		redacted.WizClientSecret = "REDACTED"
+	}
+	if proxyURL, err := url.Parse(redacted.ProxyURL); err == nil && proxyURL.User != nil {
+		redacted.ProxyURL = proxyURL.Redacted()
+	}
+	data, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
 func ArgParse() (*Arguments, error) {
 	args := &Arguments{}
 	var configFilePath string
@@ -62,6 +137,41 @@ func ArgParse() (*Arguments, error) {
 	flag.StringVar(&args.ScanSubscriptionID, "scanSubscriptionId", "", "Scan Subscription ID")
 	flag.StringVar(&args.ScanCloudType, "scanCloudType", "", "Scan Cloud Type")
 	flag.StringVar(&args.ScanProviderID, "scanProviderId", "", "Scan Provider ID")
+	flag.StringVar(&args.ResourceTypes, "resourceTypes", "VIRTUAL_MACHINE", "Comma separated Wiz entity types the scanned host is searched among")
+	flag.StringVar(&args.ResourceMatch, "resourceMatch", "externalId,name,hostname,privateIp", "Comma separated strategies used in order to find the host (externalId, name, hostname, privateIp)")
+	flag.StringVar(&args.ResourceName, "resourceName", "", "Instance name of the host in Wiz, for the name strategy")
+	flag.StringVar(&args.ProjectID, "projectId", "*", "Wiz project the host is searched in, * for all projects")
+	flag.IntVar(&args.PageSize, "pageSize", 100, "Known vulnerabilities fetched per Wiz API query")
+	flag.StringVar(&args.KnownStatus, "knownStatus", "", "Comma separated statuses the known vulnerabilities are filtered by: OPEN, RESOLVED, IGNORED, e.g. OPEN,IGNORED (all when empty)")
+	flag.StringVar(&args.KnownDataSource, "knownDataSource", "", "Comma separated data sources the known vulnerabilities are filtered by (all when empty)")
+	flag.StringVar(&args.CacheDir, "cacheDir", "", "Directory of the known vulnerabilities cache (default the user cache directory)")
+	flag.DurationVar(&args.CacheTTL, "cacheTtl", 0, "Use cached known vulnerabilities younger than this instead of querying Wiz, e.g. 6h (0 only as a fallback)")
+	flag.BoolVar(&args.NoCache, "noCache", false, "Neither read nor write the known vulnerabilities cache")
+	flag.BoolVar(&args.ClearCache, "clearCache", false, "Remove all cached known vulnerabilities before the run")
+	flag.StringVar(&args.IntegrationID, "integrationId", DefaultIntegrationID, "Wiz integration ID the findings are uploaded under")
+	flag.StringVar(&args.DataSourceID, "dataSourceId", "{{.SubscriptionID}}", "Data source ID template (fields: Hostname, RunID, SubscriptionID, ProviderID, CloudType); it must stay the same between runs, so avoid RunID")
+	flag.StringVar(&args.AnalysisDate, "analysisDate", "now", "Analysis date of the data source (now, scanStart, scanResult)")
+	flag.StringVar(&args.FindingIDScheme, "findingIdScheme", "legacy", "Finding ID scheme (legacy, v1); under v1, findings already uploaded keep their IDs")
+	flag.StringVar(&args.SuppressionFile, "suppressionFile", "", "Path to a JSON file of accepted-risk suppression rules")
+	flag.StringVar(&args.Explain, "explain", "", "Print the verdict reached for every scanned vulnerability (table, json)")
+	flag.BoolVar(&args.DryRun, "dryRun", false, "Scan and compare without uploading, writing the payload to -output")
+	flag.StringVar(&args.Output, "output", "-", "Where dry run writes the payload, a file path or - for stdout")
+	flag.StringVar(&args.RiskWeights, "riskWeights", "", "Risk score weights as name=value pairs (severity, cvss, epss, kev, exploit, fix)")
+	flag.BoolVar(&args.DropInconsistent, "dropInconsistent", false, "Drop library results whose installed version looks at or above the fixed version instead of flagging them")
+	flag.Float64Var(&args.MinRiskScore, "minRiskScore", 0, "Only upload findings with at least this risk score (0-100)")
+	flag.StringVar(&args.SeverityMap, "severityMap", "", "Extra vendor severity mappings as vendor=severity pairs, e.g. Moderate=Medium")
+	flag.StringVar(&args.DescriptionTmpl, "descriptionTemplate", "", "Path to a text/template file rendering finding descriptions")
+	flag.StringVar(&args.RemediationTmpl, "remediationTemplate", "", "Path to a text/template file rendering finding remediation")
+	flag.IntVar(&args.MaxFindingsPerUpload, "maxFindingsPerUpload", 0, "Split the payload into uploads of at most this many findings (0 for no limit)")
+	flag.IntVar(&args.MaxUploadBytes, "maxUploadBytes", 0, "Split the payload into uploads of at most this many bytes (0 for no limit)")
+	flag.IntVar(&args.MaxRetries, "maxRetries", 3, "Retries of a failed Wiz API query, with exponential backoff")
+	flag.DurationVar(&args.RequestTimeout, "requestTimeout", 60*time.Second, "Timeout of each Wiz API request, e.g. 90s (0 for none)")
+	flag.DurationVar(&args.TransferTimeout, "transferTimeout", 30*time.Minute, "Timeout of each payload upload and of the wizcli download, e.g. 1h (0 for none)")
+	flag.DurationVar(&args.Timeout, "timeout", 0, "Timeout of the whole run, e.g. 2h (0 for none)")
+	flag.StringVar(&args.ProxyURL, "proxyUrl", "", "Proxy for all outbound traffic (http, https, socks5), credentials as user:password@")
+	flag.StringVar(&args.NoProxy, "noProxy", "", "Comma separated hosts, domains, IPs and CIDRs to reach without the proxy")
+	flag.StringVar(&args.CABundle, "caBundle", "", "PEM file of extra CAs to trust, e.g. for a TLS inspecting proxy")
+	flag.BoolVar(&args.ShowConfig, "showConfig", false, "Print the effective configuration with secrets redacted and exit")
 	flag.BoolVar(&args.Save, "save", false, "Set to true to save the configuration (ignored if install flag is set)")
 	flag.StringVar(&configFilePath, "config", "config.json", "Path to the configuration file (ignored if install flag is set)")
 	flag.BoolVar(&args.Install, "install", false, "Install the application")
@@ -155,6 +265,91 @@ func validateArguments(args *Arguments) error {
 	if args.ScanProviderID == "" {
 		return errors.New("ScanProviderID is required")
 	}
+	if len(SplitList(args.ResourceTypes)) == 0 {
+		return errors.New("ResourceTypes is required")
+	}
+	for _, resourceType := range SplitList(args.ResourceTypes) {
+		if !enumPattern.MatchString(resourceType) {
+			return fmt.Errorf("invalid ResourceTypes entry: %s", resourceType)
+		}
+	}
+	if len(SplitList(args.ResourceMatch)) == 0 {
+		return errors.New("ResourceMatch is required")
+	}
+	for _, strategy := range SplitList(args.ResourceMatch) {
+		if !slices.Contains(resourceStrategies, strategy) {
+			return fmt.Errorf("invalid ResourceMatch strategy: %s", strategy)
+		}
+	}
+	if args.ProjectID == "" {
+		return errors.New("ProjectID is required")
+	}
+	if args.PageSize < 1 || args.PageSize > maxPageSize {
+		return fmt.Errorf("invalid PageSize: %d", args.PageSize)
+	}
+	for _, status := range SplitList(args.KnownStatus) {
+		if !slices.Contains(findingStatuses, status) {
+			return fmt.Errorf("invalid KnownStatus entry: %s, expected one of %s", status, strings.Join(findingStatuses, ", "))
+		}
+	}
+	if args.CacheTTL < 0 {
+		return fmt.Errorf("invalid CacheTTL: %s", args.CacheTTL)
+	}
+	if _, err := uuid.Parse(args.IntegrationID); err != nil {
+		return fmt.Errorf("invalid IntegrationID %q: %v", args.IntegrationID, err)
+	}
+	if args.DataSourceID == "" {
+		return errors.New("DataSourceID is required")
+	}
+	if _, err := template.New("dataSourceId").Parse(args.DataSourceID); err != nil {
+		return fmt.Errorf("invalid DataSourceID template: %v", err)
+	}
+	if args.AnalysisDate != "now" && args.AnalysisDate != "scanStart" && args.AnalysisDate != "scanResult" {
+		return fmt.Errorf("invalid AnalysisDate source: %s", args.AnalysisDate)
+	}
+	if args.FindingIDScheme != "v1" && args.FindingIDScheme != "legacy" {
+		return fmt.Errorf("invalid FindingIDScheme: %s", args.FindingIDScheme)
+	}
+	if args.MinRiskScore < 0 || args.MinRiskScore > 100 {
+		return fmt.Errorf("invalid MinRiskScore: %v", args.MinRiskScore)
+	}
+	if args.MaxFindingsPerUpload < 0 {
+		return fmt.Errorf("invalid MaxFindingsPerUpload: %d", args.MaxFindingsPerUpload)
+	}
+	if args.MaxUploadBytes < 0 {
+		return fmt.Errorf("invalid MaxUploadBytes: %d", args.MaxUploadBytes)
+	}
+	if args.MaxRetries < 0 {
+		return fmt.Errorf("invalid MaxRetries: %d", args.MaxRetries)
+	}
+	if args.RequestTimeout < 0 {
+		return fmt.Errorf("invalid RequestTimeout: %s", args.RequestTimeout)
+	}
+	if args.TransferTimeout < 0 {
+		return fmt.Errorf("invalid TransferTimeout: %s", args.TransferTimeout)
+	}
+	if args.Timeout < 0 {
+		return fmt.Errorf("invalid Timeout: %s", args.Timeout)
+	}
+	if args.ProxyURL != "" {
+		if _, err := parseProxyURL(args.ProxyURL); err != nil {
+			return err
+		}
+	}
+	if args.Explain != "" && args.Explain != "table" && args.Explain != "json" {
+		return fmt.Errorf("invalid Explain format: %s", args.Explain)
+	}
 
 	return nil
 }
+
+// SplitList splits a comma separated flag value, dropping blank entries.
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
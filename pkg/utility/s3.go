@@ -2,6 +2,7 @@ package utility
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,8 +10,8 @@ import (
 	"wizscan/pkg/logger"
 )
 
-// uploads a file to the provided upload URL.
-func S3Upload(uploadURL, filePath string) error {
+// uploads a file to the provided upload URL with the given client, whose timeout applies to the upload.
+func S3Upload(ctx context.Context, client *http.Client, uploadURL, filePath string) error {
 	// Open the file that needs to be uploaded.
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -25,7 +26,7 @@ func S3Upload(uploadURL, filePath string) error {
 	}
 
 	// Create a new request with the file contents
-	req, err := http.NewRequest("PUT", uploadURL, bytes.NewReader(fileContents))
+	req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, bytes.NewReader(fileContents))
 	if err != nil {
 		return fmt.Errorf("cannot create request: %v", err)
 	}
@@ -34,7 +35,6 @@ func S3Upload(uploadURL, filePath string) error {
 	req.Header.Set("Content-Type", "application/octet-stream")
 
 	// Perform the upload request
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to make request: %v", err)
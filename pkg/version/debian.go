@@ -0,0 +1,97 @@
+package version
+
+import (
+	"strings"
+)
+
+// debianVersion is a parsed [epoch:]upstream_version[-debian_revision].
+type debianVersion struct {
+	epoch    string
+	upstream string
+	revision string
+}
+
+func parseDebian(v string) debianVersion {
+	v = strings.TrimSpace(v)
+	parsed := debianVersion{epoch: "0"}
+	if i := strings.IndexByte(v, ':'); i >= 0 && isAllDigits(v[:i]) {
+		parsed.epoch = v[:i]
+		v = v[i+1:]
+	}
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		parsed.revision = v[i+1:]
+		v = v[:i]
+	}
+	parsed.upstream = v
+	return parsed
+}
+
+func compareDebian(a, b string) int {
+	va, vb := parseDebian(a), parseDebian(b)
+	if cmp := compareNumeric(va.epoch, vb.epoch); cmp != 0 {
+		return cmp
+	}
+	if cmp := verrevcmp(va.upstream, vb.upstream); cmp != 0 {
+		return cmp
+	}
+	return verrevcmp(va.revision, vb.revision)
+}
+
+// verrevcmp is dpkg's comparison of upstream versions and revisions: alternating runs of
+// non-digits, compared character by character with letters before other symbols and '~' before
+// everything including the end of the string, and runs of digits, compared by value.
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := debianOrder(a, i), debianOrder(b, j)
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// debianOrder weighs the character at s[i] for verrevcmp. Past the end of s it weighs like a digit.
+func debianOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isLetter(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
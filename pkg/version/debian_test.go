@@ -0,0 +1,36 @@
+package version
+
+import (
+	"testing"
+)
+
+// Vectors from Debian policy 5.6.12 and dpkg's version comparison tests (t-version.c).
+func TestCompareDebian(t *testing.T) {
+	checkCases(t, Debian, []compareCase{
+		{"0", "0", 0},
+		{"0", "00", 0},
+		{"1.0", "1.0", 0},
+		{"1.0.010", "1.0.10", 0},
+		{"0:1.0", "1.0", 0},
+		{"1.0", "1.0-0", 0},
+		{"1.0", "1.1", -1},
+		{"1.2", "1.10", -1},
+		{"1:0.9", "2.0", 1},
+		{"1:1.2.13.dfsg-1", "1.2.13.dfsg-2", 1},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-9", "1.0-10", -1},
+		{"2.30-1", "2.30-1ubuntu1", -1},
+		{"2.7.4+reloaded2-13ubuntu1", "2.7.4+reloaded2-13ubuntu2", -1},
+		{"7.6p2-4", "7.6-0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+b1", -1},
+		{"1.0", "1.0.1", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.0+", "1.0.", -1},
+		{"0:1.2.3-1", "1:0.1-1", -1},
+		{"3.0.2-0ubuntu1.12", "3.0.2-0ubuntu1.10", 1},
+	})
+
+	// Policy's tilde example: ~~ < ~~a < ~ < (nothing) < a
+	checkAscending(t, Debian, []string{"1.0~~", "1.0~~a", "1.0~", "1.0", "1.0a"})
+}
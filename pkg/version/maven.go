@@ -0,0 +1,240 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Maven versions are ordered like org.apache.maven.artifact.versioning.ComparableVersion: the
+// version is split into numbers and qualifiers on '.', '-' and digit/letter transitions, '-' and
+// transitions start a nested list, and well-known qualifiers have a fixed order around the
+// release.
+
+// mavenQualifiers lists the well-known qualifiers in ascending order; "" is the release itself.
+var mavenQualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+
+// mavenAliases maps alternative spellings onto the well-known qualifiers.
+var mavenAliases = map[string]string{
+	"ga":      "",
+	"final":   "",
+	"release": "",
+	"cr":      "rc",
+}
+
+var mavenReleaseIndex = strconv.Itoa(indexOf(mavenQualifiers, ""))
+
+// mavenItem is a number, a qualifier or a nested list. compareTo accepts nil, which stands for
+// a missing item when one version has fewer parts than the other.
+type mavenItem interface {
+	compareTo(other mavenItem) int
+	isNull() bool
+}
+
+type mavenInt string    // Digits without leading zeros
+type mavenString string // Normalized qualifier
+type mavenList []mavenItem
+
+func (i mavenInt) isNull() bool    { return i == "0" }
+func (s mavenString) isNull() bool { return comparableQualifier(string(s)) == mavenReleaseIndex }
+func (l mavenList) isNull() bool   { return len(l) == 0 }
+
+func (i mavenInt) compareTo(other mavenItem) int {
+	switch o := other.(type) {
+	case nil:
+		if i.isNull() {
+			return 0
+		}
+		return 1
+	case mavenInt:
+		return compareNumeric(string(i), string(o))
+	default:
+		// Numbers sort above qualifiers and nested lists
+		return 1
+	}
+}
+
+func (s mavenString) compareTo(other mavenItem) int {
+	switch o := other.(type) {
+	case nil:
+		return strings.Compare(comparableQualifier(string(s)), mavenReleaseIndex)
+	case mavenString:
+		return strings.Compare(comparableQualifier(string(s)), comparableQualifier(string(o)))
+	default:
+		return -1
+	}
+}
+
+func (l mavenList) compareTo(other mavenItem) int {
+	switch o := other.(type) {
+	case nil:
+		if len(l) == 0 {
+			return 0
+		}
+		return l[0].compareTo(nil)
+	case mavenInt:
+		return -1
+	case mavenString:
+		return 1
+	case mavenList:
+		for i := 0; i < len(l) || i < len(o); i++ {
+			var left, right mavenItem
+			if i < len(l) {
+				left = l[i]
+			}
+			if i < len(o) {
+				right = o[i]
+			}
+			var cmp int
+			if left == nil {
+				if right != nil {
+					cmp = -right.compareTo(nil)
+				}
+			} else {
+				cmp = left.compareTo(right)
+			}
+			if cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+// comparableQualifier turns a qualifier into a string that sorts in qualifier order: the index of
+// a well-known qualifier, or the qualifier itself after all of them.
+func comparableQualifier(q string) string {
+	if i := indexOf(mavenQualifiers, q); i >= 0 {
+		return strconv.Itoa(i)
+	}
+	return strconv.Itoa(len(mavenQualifiers)) + "-" + q
+}
+
+// newMavenString normalizes a qualifier. Single letter a, b and m directly followed by a number
+// are shorthand for alpha, beta and milestone.
+func newMavenString(s string, followedByDigit bool) mavenString {
+	if followedByDigit && len(s) == 1 {
+		switch s {
+		case "a":
+			s = "alpha"
+		case "b":
+			s = "beta"
+		case "m":
+			s = "milestone"
+		}
+	}
+	if alias, ok := mavenAliases[s]; ok {
+		s = alias
+	}
+	return mavenString(s)
+}
+
+func newMavenItem(isDigit bool, s string) mavenItem {
+	if isDigit {
+		return mavenInt(trimLeadingZeros(s))
+	}
+	return newMavenString(s, false)
+}
+
+// parseMaven splits a version into its nested item lists.
+func parseMaven(v string) mavenList {
+	v = strings.ToLower(strings.TrimSpace(v))
+
+	// Lists are built through pointers so nested lists can be appended to after being linked
+	root := &mavenList{}
+	lists := []*mavenList{root}
+	current := root
+	openList := func() {
+		nested := &mavenList{}
+		lists = append(lists, nested)
+		*current = append(*current, nestedListRef{nested})
+		current = nested
+	}
+
+	inDigits := false
+	start := 0
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '.' || c == '-':
+			if i == start {
+				*current = append(*current, mavenInt("0"))
+			} else {
+				*current = append(*current, newMavenItem(inDigits, v[start:i]))
+			}
+			start = i + 1
+			if c == '-' {
+				openList()
+			}
+		case isDigit(c):
+			if !inDigits && i > start {
+				*current = append(*current, newMavenString(v[start:i], true))
+				start = i
+				openList()
+			}
+			inDigits = true
+		default:
+			if inDigits && i > start {
+				*current = append(*current, newMavenItem(true, v[start:i]))
+				start = i
+				openList()
+			}
+			inDigits = false
+		}
+	}
+	if len(v) > start {
+		*current = append(*current, newMavenItem(inDigits, v[start:]))
+	}
+
+	// Normalize innermost lists first so that emptied lists are trimmed from their parents
+	for i := len(lists) - 1; i >= 0; i-- {
+		*lists[i] = normalizeMavenList(resolveNestedLists(*lists[i]))
+	}
+	return resolveNestedLists(*root)
+}
+
+// nestedListRef links a nested list while the version is still being parsed.
+type nestedListRef struct{ list *mavenList }
+
+func (n nestedListRef) compareTo(other mavenItem) int { return (*n.list).compareTo(other) }
+func (n nestedListRef) isNull() bool                  { return (*n.list).isNull() }
+
+// resolveNestedLists replaces the parse-time links with the lists they point to.
+func resolveNestedLists(l mavenList) mavenList {
+	resolved := make(mavenList, len(l))
+	for i, item := range l {
+		if ref, ok := item.(nestedListRef); ok {
+			resolved[i] = *ref.list
+		} else {
+			resolved[i] = item
+		}
+	}
+	return resolved
+}
+
+// normalizeMavenList drops trailing null items (zeros, release qualifiers and empty lists) so
+// that 1.0, 1.0.0 and 1-ga compare equal to 1.
+func normalizeMavenList(l mavenList) mavenList {
+	for i := len(l) - 1; i >= 0; i-- {
+		if l[i].isNull() {
+			l = append(l[:i], l[i+1:]...)
+		} else if _, isList := l[i].(mavenList); !isList {
+			break
+		}
+	}
+	return l
+}
+
+func compareMaven(a, b string) int {
+	return sign(parseMaven(a).compareTo(parseMaven(b)))
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,106 @@
+package version
+
+import (
+	"testing"
+)
+
+// Vectors from Maven's ComparableVersionTest.
+func TestCompareMavenQualifiers(t *testing.T) {
+	checkAscending(t, Maven, []string{
+		"1-alpha2snapshot",
+		"1-alpha2",
+		"1-alpha-123",
+		"1-beta-2",
+		"1-beta123",
+		"1-m2",
+		"1-m11",
+		"1-rc",
+		"1-cr2",
+		"1-rc123",
+		"1-SNAPSHOT",
+		"1",
+		"1-sp",
+		"1-sp2",
+		"1-sp123",
+		"1-abc",
+		"1-def",
+		"1-pom-1",
+		"1-1-snapshot",
+		"1-1",
+		"1-2",
+		"1-123",
+	})
+}
+
+func TestCompareMavenNumbers(t *testing.T) {
+	checkAscending(t, Maven, []string{
+		"2.0",
+		"2-1",
+		"2.0.a",
+		"2.0.0.a",
+		"2.0.2",
+		"2.0.123",
+		"2.1.0",
+		"2.1-a",
+		"2.1b",
+		"2.1-c",
+		"2.1-1",
+		"2.1.0.1",
+		"2.2",
+		"2.123",
+		"11.a2",
+		"11.a11",
+		"11.b2",
+		"11.b11",
+		"11.m2",
+		"11.m11",
+		"11",
+		"11.a",
+		"11b",
+		"11c",
+		"11m",
+	})
+}
+
+func TestCompareMavenEqual(t *testing.T) {
+	checkCases(t, Maven, []compareCase{
+		{"1", "1", 0},
+		{"1", "1.0", 0},
+		{"1", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1", "1-0", 0},
+		{"1", "1.0-0", 0},
+		{"1.0", "1.0-0", 0},
+		{"1a", "1-a", 0},
+		{"1a", "1.0-a", 0},
+		{"1a", "1.0.0-a", 0},
+		{"1.0a", "1-a", 0},
+		{"1.0.0a", "1-a", 0},
+		{"1x", "1-x", 0},
+		{"1x", "1.0-x", 0},
+		{"1x", "1.0.0-x", 0},
+		{"1.0x", "1-x", 0},
+		{"1.0.0x", "1-x", 0},
+		{"1ga", "1", 0},
+		{"1release", "1", 0},
+		{"1final", "1", 0},
+		{"1cr", "1rc", 0},
+		{"1a1", "1-alpha-1", 0},
+		{"1b2", "1-beta-2", 0},
+		{"1m3", "1-milestone-3", 0},
+		{"1X", "1x", 0},
+		{"1A", "1a", 0},
+		{"1B", "1b", 0},
+		{"1M", "1m", 0},
+		{"1Ga", "1", 0},
+		{"1GA", "1", 0},
+		{"1RELEASE", "1", 0},
+		{"1Final", "1", 0},
+		{"1FINAL", "1", 0},
+		{"1Cr", "1Rc", 0},
+		{"1cR", "1rC", 0},
+		{"1m3", "1Milestone3", 0},
+		{"1m3", "1MileStone3", 0},
+		{"1m3", "1MILESTONE3", 0},
+	})
+}
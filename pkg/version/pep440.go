@@ -0,0 +1,175 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// pep440Pattern is the version grammar from PEP 440, accepting the same spellings pip normalizes.
+var pep440Pattern = regexp.MustCompile(`(?i)^\s*v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?:[-_.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?:-(?P<post_n1>[0-9]+)|[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?)?` +
+	`(?:[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?\s*$`)
+
+// Pre-release phases in order. A dev release of a final version sorts before all of them and a
+// final release after all of them.
+const (
+	pep440PhaseDev = iota
+	pep440PhaseAlpha
+	pep440PhaseBeta
+	pep440PhaseRC
+	pep440PhaseFinal
+)
+
+// pep440Version is a parsed PEP 440 version.
+type pep440Version struct {
+	epoch    string
+	release  []string
+	phase    int
+	preNum   string
+	hasPost  bool
+	postNum  string
+	hasDev   bool
+	devNum   string
+	local    []string
+	hasLocal bool
+}
+
+func parsePEP440(v string) (pep440Version, error) {
+	m := pep440Pattern.FindStringSubmatch(v)
+	if m == nil {
+		return pep440Version{}, fmt.Errorf("invalid PEP 440 version %q", v)
+	}
+	group := func(name string) string {
+		return strings.ToLower(m[pep440Pattern.SubexpIndex(name)])
+	}
+
+	parsed := pep440Version{
+		epoch:   orZero(group("epoch")),
+		release: strings.Split(group("release"), "."),
+		phase:   pep440PhaseFinal,
+	}
+
+	switch group("pre_l") {
+	case "a", "alpha":
+		parsed.phase = pep440PhaseAlpha
+	case "b", "beta":
+		parsed.phase = pep440PhaseBeta
+	case "c", "rc", "pre", "preview":
+		parsed.phase = pep440PhaseRC
+	}
+	parsed.preNum = orZero(group("pre_n"))
+
+	if n := group("post_n1"); n != "" {
+		parsed.hasPost, parsed.postNum = true, n
+	} else if group("post_l") != "" {
+		parsed.hasPost, parsed.postNum = true, orZero(group("post_n2"))
+	}
+
+	if group("dev_l") != "" {
+		parsed.hasDev, parsed.devNum = true, orZero(group("dev_n"))
+		// A dev release without a pre or post release sorts before every pre-release
+		if parsed.phase == pep440PhaseFinal && !parsed.hasPost {
+			parsed.phase = pep440PhaseDev
+		}
+	}
+
+	if local := group("local"); local != "" {
+		parsed.hasLocal = true
+		parsed.local = strings.FieldsFunc(local, func(r rune) bool { return r == '.' || r == '-' || r == '_' })
+	}
+
+	return parsed, nil
+}
+
+func comparePEP440(a, b string) (int, error) {
+	va, err := parsePEP440(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parsePEP440(b)
+	if err != nil {
+		return 0, err
+	}
+
+	if cmp := compareNumeric(va.epoch, vb.epoch); cmp != 0 {
+		return cmp, nil
+	}
+	for i := 0; i < len(va.release) || i < len(vb.release); i++ {
+		if cmp := compareNumeric(partOrZero(va.release, i), partOrZero(vb.release, i)); cmp != 0 {
+			return cmp, nil
+		}
+	}
+	if cmp := sign(va.phase - vb.phase); cmp != 0 {
+		return cmp, nil
+	}
+	if va.phase != pep440PhaseFinal && va.phase != pep440PhaseDev {
+		if cmp := compareNumeric(va.preNum, vb.preNum); cmp != 0 {
+			return cmp, nil
+		}
+	}
+	// No post release sorts before any post release
+	if cmp := compareOptional(va.hasPost, va.postNum, vb.hasPost, vb.postNum, false); cmp != 0 {
+		return cmp, nil
+	}
+	// No dev release sorts after any dev release
+	if cmp := compareOptional(va.hasDev, va.devNum, vb.hasDev, vb.devNum, true); cmp != 0 {
+		return cmp, nil
+	}
+	return compareLocal(va, vb), nil
+}
+
+// compareOptional compares two optional numbers, where absentIsHigher decides how a missing
+// number sorts against a present one.
+func compareOptional(aHas bool, a string, bHas bool, b string, absentIsHigher bool) int {
+	switch {
+	case aHas && bHas:
+		return compareNumeric(a, b)
+	case aHas == bHas:
+		return 0
+	case aHas == absentIsHigher:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// compareLocal orders local version labels: no label sorts first, numeric segments sort above
+// alphanumeric ones, and a longer label wins when one is a prefix of the other.
+func compareLocal(a, b pep440Version) int {
+	if a.hasLocal != b.hasLocal {
+		if a.hasLocal {
+			return 1
+		}
+		return -1
+	}
+	for i := 0; i < len(a.local) && i < len(b.local); i++ {
+		aNumeric, bNumeric := isAllDigits(a.local[i]), isAllDigits(b.local[i])
+		var cmp int
+		switch {
+		case aNumeric && bNumeric:
+			cmp = compareNumeric(a.local[i], b.local[i])
+		case aNumeric:
+			cmp = 1
+		case bNumeric:
+			cmp = -1
+		default:
+			cmp = strings.Compare(a.local[i], b.local[i])
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return sign(len(a.local) - len(b.local))
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
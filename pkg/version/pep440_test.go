@@ -0,0 +1,66 @@
+package version
+
+import (
+	"testing"
+)
+
+// Ordering from PEP 440's examples and the packaging library's version tests.
+func TestComparePEP440(t *testing.T) {
+	checkAscending(t, PEP440, []string{
+		"1.0.dev456",
+		"1.0a1",
+		"1.0a2.dev456",
+		"1.0a12.dev456",
+		"1.0a12",
+		"1.0b1.dev456",
+		"1.0b2",
+		"1.0b2.post345.dev456",
+		"1.0b2.post345",
+		"1.0rc1.dev456",
+		"1.0rc1",
+		"1.0",
+		"1.0+abc.5",
+		"1.0+abc.7",
+		"1.0+5",
+		"1.0.post456.dev34",
+		"1.0.post456",
+		"1.0.15",
+		"1.1.dev1",
+		"1!0.1",
+	})
+
+	// Alternative spellings normalize to the same version
+	checkCases(t, PEP440, []compareCase{
+		{"1.0", "1.0.0", 0},
+		{"1.0", "1.0.0.0", 0},
+		{"v1.0", "1.0", 0},
+		{"0!1.0", "1.0", 0},
+		{"1.0a1", "1.0alpha1", 0},
+		{"1.0a1", "1.0.a1", 0},
+		{"1.0a1", "1.0-a1", 0},
+		{"1.0a", "1.0a0", 0},
+		{"1.0b1", "1.0beta1", 0},
+		{"1.0rc1", "1.0c1", 0},
+		{"1.0rc1", "1.0pre1", 0},
+		{"1.0rc1", "1.0preview1", 0},
+		{"1.0.post1", "1.0post1", 0},
+		{"1.0.post1", "1.0-1", 0},
+		{"1.0.post1", "1.0.rev1", 0},
+		{"1.0.post1", "1.0-r1", 0},
+		{"1.0.post0", "1.0.post", 0},
+		{"1.0.dev0", "1.0.dev", 0},
+		{"1.0+ABC", "1.0+abc", 0},
+		{"1.0+abc-5", "1.0+abc.5", 0},
+		{"1.0RC1", "1.0rc1", 0},
+		{"1.10", "1.9", 1},
+		{"2.0", "10.0", -1},
+	})
+}
+
+func TestComparePEP440Invalid(t *testing.T) {
+	for _, v := range []string{"", "foo", "1.0-foo", "1.0+", "1..0", "1.0+abc+def"} {
+		if _, err := Compare(PEP440, v, "1.0"); err == nil {
+			t.Errorf("Compare(pep440, %q, \"1.0\"): expected an error", v)
+		}
+	}
+}
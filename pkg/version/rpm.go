@@ -0,0 +1,147 @@
+package version
+
+import (
+	"strings"
+)
+
+// rpmVersion is a parsed [epoch:]version[-release].
+type rpmVersion struct {
+	epoch   string
+	version string
+	release string
+}
+
+func parseRPM(v string) rpmVersion {
+	v = strings.TrimSpace(v)
+	parsed := rpmVersion{epoch: "0"}
+	if i := strings.IndexByte(v, ':'); i >= 0 && isAllDigits(v[:i]) {
+		parsed.epoch = v[:i]
+		v = v[i+1:]
+	}
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		parsed.release = v[i+1:]
+		v = v[:i]
+	}
+	parsed.version = v
+	return parsed
+}
+
+func compareRPM(a, b string) int {
+	va, vb := parseRPM(a), parseRPM(b)
+	if cmp := compareNumeric(va.epoch, vb.epoch); cmp != 0 {
+		return cmp
+	}
+	if cmp := rpmvercmp(va.version, vb.version); cmp != 0 {
+		return cmp
+	}
+	// A missing release matches any release, like rpm does for dependency ranges
+	if va.release == "" || vb.release == "" {
+		return 0
+	}
+	return rpmvercmp(va.release, vb.release)
+}
+
+// rpmvercmp is rpm's segment comparison: versions are split into runs of digits and runs of
+// letters, separators are ignored, '~' sorts before anything (pre-releases) and '^' sorts after
+// the end of the version but before any further segment (snapshots).
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isRPMSegmentChar(a[i]) {
+			i++
+		}
+		for j < len(b) && !isRPMSegmentChar(b[j]) {
+			j++
+		}
+
+		aAt, bAt := byteAt(a, i), byteAt(b, j)
+		if aAt == '~' || bAt == '~' {
+			if aAt != '~' {
+				return 1
+			}
+			if bAt != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if aAt == '^' || bAt == '^' {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if aAt != '^' {
+				return 1
+			}
+			if bAt != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+
+		// Take a segment of the same kind from both sides
+		numeric := isDigit(a[i])
+		belongs := isLetter
+		if numeric {
+			belongs = isDigit
+		}
+		startA, startB := i, j
+		for i < len(a) && belongs(a[i]) {
+			i++
+		}
+		for j < len(b) && belongs(b[j]) {
+			j++
+		}
+		segA, segB := a[startA:i], b[startB:j]
+
+		// Segments of different kinds: numbers are newer than letters
+		if segB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		var cmp int
+		if numeric {
+			cmp = compareNumeric(segA, segB)
+		} else {
+			cmp = strings.Compare(segA, segB)
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i < len(a):
+		return 1
+	default:
+		return -1
+	}
+}
+
+func isRPMSegmentChar(c byte) bool {
+	return isDigit(c) || isLetter(c) || c == '~' || c == '^'
+}
+
+func byteAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
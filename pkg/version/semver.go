@@ -0,0 +1,99 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// semver is a parsed semantic version.
+type semver struct {
+	core       []string // Numeric parts, at least one
+	prerelease []string // Dot separated pre-release identifiers, empty for a release
+}
+
+// parseSemver parses a semantic version. Build metadata is dropped since it does not affect ordering.
+func parseSemver(v string) (semver, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var parsed semver
+	core := s
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		core = s[:i]
+		parsed.prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range parsed.prerelease {
+			if id == "" {
+				return semver{}, fmt.Errorf("invalid semantic version %q: empty pre-release identifier", v)
+			}
+		}
+	}
+
+	parsed.core = strings.Split(core, ".")
+	for _, part := range parsed.core {
+		if !isAllDigits(part) {
+			return semver{}, fmt.Errorf("invalid semantic version %q", v)
+		}
+	}
+
+	return parsed, nil
+}
+
+func compareSemver(a, b string) (int, error) {
+	va, err := parseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// Missing numeric parts count as zero, so 1.2 == 1.2.0
+	for i := 0; i < len(va.core) || i < len(vb.core); i++ {
+		if cmp := compareNumeric(partOrZero(va.core, i), partOrZero(vb.core, i)); cmp != 0 {
+			return cmp, nil
+		}
+	}
+
+	// A pre-release sorts before the release it precedes
+	switch {
+	case len(va.prerelease) == 0 && len(vb.prerelease) == 0:
+		return 0, nil
+	case len(va.prerelease) == 0:
+		return 1, nil
+	case len(vb.prerelease) == 0:
+		return -1, nil
+	}
+
+	for i := 0; i < len(va.prerelease) && i < len(vb.prerelease); i++ {
+		if cmp := comparePrereleaseIdentifier(va.prerelease[i], vb.prerelease[i]); cmp != 0 {
+			return cmp, nil
+		}
+	}
+	return sign(len(va.prerelease) - len(vb.prerelease)), nil
+}
+
+// comparePrereleaseIdentifier orders numeric identifiers by value and below alphanumeric ones,
+// which are ordered lexically.
+func comparePrereleaseIdentifier(a, b string) int {
+	aNumeric, bNumeric := isAllDigits(a), isAllDigits(b)
+	switch {
+	case aNumeric && bNumeric:
+		return compareNumeric(a, b)
+	case aNumeric:
+		return -1
+	case bNumeric:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func partOrZero(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
@@ -0,0 +1,41 @@
+package version
+
+import (
+	"testing"
+)
+
+// Precedence examples from the Semantic Versioning 2.0.0 specification.
+func TestCompareSemver(t *testing.T) {
+	checkAscending(t, Semver, []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"2.0.0",
+		"2.1.0",
+		"2.1.1",
+		"2.10.0",
+	})
+
+	checkCases(t, Semver, []compareCase{
+		{"1.0.0+build.1", "1.0.0", 0},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3.4", "1.2.3", 1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-01", "1.0.0-1", 0},
+	})
+}
+
+func TestCompareSemverInvalid(t *testing.T) {
+	for _, v := range []string{"", "abc", "1.x.0", "1.0.0-", "1.0.0-alpha..1"} {
+		if _, err := Compare(Semver, v, "1.0.0"); err == nil {
+			t.Errorf("Compare(semver, %q, \"1.0.0\"): expected an error", v)
+		}
+	}
+}
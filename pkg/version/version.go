@@ -0,0 +1,118 @@
+// Package version orders package version strings according to the rules of the ecosystem they
+// come from.
+package version
+
+import (
+	"fmt"
+)
+
+// Scheme names a version ordering.
+type Scheme string
+
+const (
+	Semver Scheme = "semver" // Semantic Versioning 2.0.0, leniently accepting a leading "v" and any number of numeric parts
+	PEP440 Scheme = "pep440" // Python packages
+	Maven  Scheme = "maven"  // Maven artifacts, following ComparableVersion
+	Debian Scheme = "debian" // Debian packages, following dpkg
+	RPM    Scheme = "rpm"    // RPM packages, following rpmvercmp
+)
+
+// Compare returns -1, 0 or +1 depending on whether a is lower than, equal to or higher than b
+// under the given scheme. It returns an error when the scheme is unknown or when either version
+// is not valid under a scheme with a strict grammar.
+func Compare(scheme Scheme, a, b string) (int, error) {
+	switch scheme {
+	case Semver:
+		return compareSemver(a, b)
+	case PEP440:
+		return comparePEP440(a, b)
+	case Maven:
+		return compareMaven(a, b), nil
+	case Debian:
+		return compareDebian(a, b), nil
+	case RPM:
+		return compareRPM(a, b), nil
+	default:
+		return 0, fmt.Errorf("unknown version scheme: %q", scheme)
+	}
+}
+
+// Max returns the highest of the given versions under the scheme, skipping empty strings. It
+// returns an empty string when there is nothing to compare.
+func Max(scheme Scheme, versions ...string) (string, error) {
+	highest := ""
+	for _, v := range versions {
+		if v == "" {
+			continue
+		}
+		if highest == "" {
+			highest = v
+			continue
+		}
+		cmp, err := Compare(scheme, v, highest)
+		if err != nil {
+			return "", err
+		}
+		if cmp > 0 {
+			highest = v
+		}
+	}
+	return highest, nil
+}
+
+// sign collapses an integer comparison result to -1, 0 or +1.
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareNumeric compares two strings of ASCII digits by value without converting them, so that
+// arbitrarily long numbers are handled.
+func compareNumeric(a, b string) int {
+	a = trimLeadingZeros(a)
+	b = trimLeadingZeros(b)
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func trimLeadingZeros(s string) string {
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
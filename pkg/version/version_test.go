@@ -0,0 +1,80 @@
+package version
+
+import (
+	"testing"
+)
+
+// compareCase is one expected comparison result.
+type compareCase struct {
+	a, b string
+	want int
+}
+
+// checkCases compares every case in both directions, expecting the opposite result the other way.
+func checkCases(t *testing.T, scheme Scheme, cases []compareCase) {
+	t.Helper()
+	for _, c := range cases {
+		got, err := Compare(scheme, c.a, c.b)
+		if err != nil {
+			t.Errorf("Compare(%s, %q, %q): unexpected error: %v", scheme, c.a, c.b, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Compare(%s, %q, %q) = %d, want %d", scheme, c.a, c.b, got, c.want)
+		}
+		if reverse, _ := Compare(scheme, c.b, c.a); reverse != -c.want {
+			t.Errorf("Compare(%s, %q, %q) = %d, want %d", scheme, c.b, c.a, reverse, -c.want)
+		}
+	}
+}
+
+// checkAscending expects every version of the list to sort below all of the versions after it.
+func checkAscending(t *testing.T, scheme Scheme, ascending []string) {
+	t.Helper()
+	var cases []compareCase
+	for i := range ascending {
+		for j := i + 1; j < len(ascending); j++ {
+			cases = append(cases, compareCase{ascending[i], ascending[j], -1})
+		}
+	}
+	checkCases(t, scheme, cases)
+}
+
+func TestCompareUnknownScheme(t *testing.T) {
+	if _, err := Compare("npm", "1.0", "2.0"); err == nil {
+		t.Error("Compare with an unknown scheme: expected an error")
+	}
+}
+
+func TestMax(t *testing.T) {
+	tests := []struct {
+		scheme   Scheme
+		versions []string
+		want     string
+	}{
+		{Semver, nil, ""},
+		{Semver, []string{"", ""}, ""},
+		{Semver, []string{"1.2.3"}, "1.2.3"},
+		{Semver, []string{"1.2.3", "", "1.10.0", "1.9.9"}, "1.10.0"},
+		{PEP440, []string{"2.0rc1", "2.0", "1.9.post1"}, "2.0"},
+		{Maven, []string{"1.0-SNAPSHOT", "1.0", "1.0-rc1"}, "1.0"},
+		{Debian, []string{"1:0.9", "2.0"}, "1:0.9"},
+		{RPM, []string{"1.0~rc1", "1.0"}, "1.0"},
+	}
+	for _, tt := range tests {
+		got, err := Max(tt.scheme, tt.versions...)
+		if err != nil {
+			t.Errorf("Max(%s, %q): unexpected error: %v", tt.scheme, tt.versions, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Max(%s, %q) = %q, want %q", tt.scheme, tt.versions, got, tt.want)
+		}
+	}
+}
+
+func TestMaxInvalid(t *testing.T) {
+	if _, err := Max(PEP440, "1.0", "not a version"); err == nil {
+		t.Error("Max with an invalid PEP 440 version: expected an error")
+	}
+}
@@ -0,0 +1,55 @@
+package vulnerability
+
+import (
+	"wizscan/pkg/version"
+	"wizscan/pkg/wizcli"
+)
+
+// fixApplicability says whether a fixed version is actually newer than the installed version.
+type fixApplicability int
+
+const (
+	fixUnverified       fixApplicability = iota // No fixed version, unknown ecosystem or unparsable versions
+	fixApplies                                  // The installed version is below the fixed version
+	fixAlreadyInstalled                         // The installed version is at or above the fixed version
+)
+
+// checkFixApplicability compares the installed version of a component with the version a
+// vulnerability is fixed in.
+func checkFixApplicability(ecosystem Ecosystem, installed, fixed string) fixApplicability {
+	scheme, known := ecosystem.VersionScheme()
+	if !known || installed == "" || fixed == "" {
+		return fixUnverified
+	}
+	cmp, err := version.Compare(scheme, installed, fixed)
+	if err != nil {
+		return fixUnverified
+	}
+	if cmp < 0 {
+		return fixApplies
+	}
+	return fixAlreadyInstalled
+}
+
+// remediationTarget returns the lowest version of the library that fixes all of its applicable
+// vulnerabilities, i.e. the highest of their fixed versions. It returns an empty string when the
+// versions cannot be ordered.
+func remediationTarget(lib wizcli.Library, ecosystem Ecosystem) string {
+	scheme, known := ecosystem.VersionScheme()
+	if !known {
+		return ""
+	}
+
+	target := ""
+	for _, vuln := range lib.Vulnerabilities {
+		if checkFixApplicability(ecosystem, lib.Version, vuln.FixedVersion) != fixApplies {
+			continue
+		}
+		highest, err := version.Max(scheme, target, vuln.FixedVersion)
+		if err != nil {
+			continue
+		}
+		target = highest
+	}
+	return target
+}
@@ -0,0 +1,128 @@
+package vulnerability
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"wizscan/pkg/logger"
+)
+
+// MarshalPayload encodes enrichment data the way it is uploaded.
+func MarshalPayload(data IntegrationData) ([]byte, error) {
+	payload, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return payload, nil
+}
+
+// PartDataSourceID returns the data source ID of the given zero-based part of a split upload. Wiz
+// treats every upload as the full snapshot of its data source, so each part is its own data source:
+// the first part keeps the ID, which an unsplit upload also uses, and later parts get a numbered
+// suffix, e.g. "sub-1234-2".
+func PartDataSourceID(id string, part int) string {
+	if part == 0 {
+		return id
+	}
+	return fmt.Sprintf("%s-%d", id, part+1)
+}
+
+// SplitIntegrationData splits enrichment data into chunks of at most maxFindings findings and
+// maxBytes encoded bytes, a limit of 0 meaning no limit. Every chunk holds a single asset under its
+// own part of the data source (see PartDataSourceID), so uploading one never closes the findings of
+// another. Findings are split in ID order, so a finding stays in its part while the number of parts
+// does not change. Assets without findings still get a chunk, since an empty asset is how all of
+// its findings are resolved, and a data source split into fewer than minParts parts is padded with
+// empty parts so the findings of parts uploaded by an earlier run are closed. It also returns the
+// number of parts holding the data, without the padding, which is the minParts of the next run.
+func SplitIntegrationData(data IntegrationData, maxFindings, maxBytes, minParts int) ([][]byte, int, error) {
+	chunks := make([][]byte, 0)
+	dataParts := 0
+	for _, dataSource := range data.DataSources {
+		parts := make([]Asset, 0)
+		for _, asset := range dataSource.Assets {
+			findings := append([]VulnerabilityFinding(nil), asset.VulnerabilityFindings...)
+			sort.SliceStable(findings, func(i, j int) bool { return findings[i].Id < findings[j].Id })
+			for _, group := range splitByCount(findings, maxFindings) {
+				split, err := splitBySize(data, dataSource, asset, group, maxBytes)
+				if err != nil {
+					return nil, 0, err
+				}
+				parts = append(parts, split...)
+			}
+		}
+		dataParts += len(parts)
+		for len(parts) > 0 && len(parts) < minParts {
+			empty := parts[len(parts)-1]
+			empty.VulnerabilityFindings = []VulnerabilityFinding{}
+			parts = append(parts, empty)
+		}
+
+		for i, asset := range parts {
+			encoded, err := encodePart(data, dataSource, i, asset)
+			if err != nil {
+				return nil, 0, err
+			}
+			chunks = append(chunks, encoded)
+		}
+	}
+	return chunks, dataParts, nil
+}
+
+// splitByCount cuts the findings into slices of at most max findings, always returning at least one.
+func splitByCount(findings []VulnerabilityFinding, max int) [][]VulnerabilityFinding {
+	if max <= 0 || len(findings) <= max {
+		return [][]VulnerabilityFinding{findings}
+	}
+	parts := make([][]VulnerabilityFinding, 0, (len(findings)+max-1)/max)
+	for start := 0; start < len(findings); start += max {
+		end := start + max
+		if end > len(findings) {
+			end = len(findings)
+		}
+		parts = append(parts, findings[start:end])
+	}
+	return parts
+}
+
+// splitBySize halves the findings until every part encodes to at most maxBytes, returning one
+// asset per part. Parts are measured under the longest part ID, so numbering them afterwards never
+// pushes one over the limit. A single finding larger than the limit is kept on its own with a warning.
+func splitBySize(data IntegrationData, dataSource DataSource, asset Asset, findings []VulnerabilityFinding, maxBytes int) ([]Asset, error) {
+	asset.VulnerabilityFindings = findings
+	if maxBytes <= 0 || len(findings) == 0 {
+		return []Asset{asset}, nil
+	}
+
+	payload, err := encodePart(data, dataSource, math.MaxInt32, asset)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) <= maxBytes {
+		return []Asset{asset}, nil
+	}
+	if len(findings) == 1 {
+		logger.Log.Warnf("Finding %s alone encodes to %d bytes, above the %d byte upload limit", findings[0].Id, len(payload), maxBytes)
+		return []Asset{asset}, nil
+	}
+
+	half := len(findings) / 2
+	first, err := splitBySize(data, dataSource, asset, findings[:half], maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	second, err := splitBySize(data, dataSource, asset, findings[half:], maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+// encodePart encodes the asset alone under the given part of the data source.
+func encodePart(data IntegrationData, dataSource DataSource, part int, asset Asset) ([]byte, error) {
+	dataSource.Id = PartDataSourceID(dataSource.Id, part)
+	dataSource.Assets = []Asset{asset}
+	data.DataSources = []DataSource{dataSource}
+	return MarshalPayload(data)
+}
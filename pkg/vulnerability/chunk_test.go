@@ -0,0 +1,192 @@
+package vulnerability
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunkData builds enrichment data with one asset holding n findings, listed in reverse ID order.
+func chunkData(n int) IntegrationData {
+	findings := make([]VulnerabilityFinding, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		findings = append(findings, VulnerabilityFinding{
+			Id:           fmt.Sprintf("WIZCLI-%03d", i),
+			Name:         fmt.Sprintf("CVE-2024-%05d", i),
+			DetailedName: "openssl",
+			Severity:     "High",
+		})
+	}
+	return IntegrationData{
+		IntegrationId: "integration",
+		DataSources: []DataSource{{
+			Id:           "sub-1234",
+			AnalysisDate: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+			Assets: []Asset{{
+				AssetIdentifier:       AssetIdentifier{CloudPlatform: "AWS", ProviderId: "i-0123"},
+				VulnerabilityFindings: findings,
+			}},
+		}},
+	}
+}
+
+// decodeChunks decodes split chunks, checking each holds one data source with one asset.
+func decodeChunks(t *testing.T, chunks [][]byte) []DataSource {
+	t.Helper()
+	dataSources := make([]DataSource, 0, len(chunks))
+	for i, chunk := range chunks {
+		var data IntegrationData
+		if err := json.Unmarshal(chunk, &data); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if len(data.DataSources) != 1 || len(data.DataSources[0].Assets) != 1 {
+			t.Fatalf("chunk %d has %d data sources, want 1 with 1 asset", i, len(data.DataSources))
+		}
+		dataSources = append(dataSources, data.DataSources[0])
+	}
+	return dataSources
+}
+
+func TestPartDataSourceID(t *testing.T) {
+	cases := []struct {
+		part int
+		want string
+	}{
+		{0, "sub-1234"},
+		{1, "sub-1234-2"},
+		{9, "sub-1234-10"},
+	}
+	for _, c := range cases {
+		if got := PartDataSourceID("sub-1234", c.part); got != c.want {
+			t.Errorf("PartDataSourceID(%d) = %q, want %q", c.part, got, c.want)
+		}
+	}
+}
+
+func TestSplitIntegrationData(t *testing.T) {
+	cases := []struct {
+		name        string
+		findings    int
+		maxFindings int
+		minParts    int
+		sizes       []int // Findings per chunk
+		dataParts   int
+	}{
+		{"no limit", 10, 0, 0, []int{10}, 1},
+		{"under the limit", 10, 10, 0, []int{10}, 1},
+		{"count limit", 10, 4, 0, []int{4, 4, 2}, 3},
+		{"no findings", 0, 4, 0, []int{0}, 1},
+		{"padded", 5, 4, 4, []int{4, 1, 0, 0}, 2},
+		{"all closed", 0, 4, 3, []int{0, 0, 0}, 1},
+		{"fewer parts than before", 10, 4, 2, []int{4, 4, 2}, 3},
+	}
+	for _, c := range cases {
+		chunks, dataParts, err := SplitIntegrationData(chunkData(c.findings), c.maxFindings, 0, c.minParts)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if dataParts != c.dataParts {
+			t.Errorf("%s: %d parts with data, want %d", c.name, dataParts, c.dataParts)
+		}
+		dataSources := decodeChunks(t, chunks)
+		if len(dataSources) != len(c.sizes) {
+			t.Fatalf("%s: %d chunks, want %d", c.name, len(dataSources), len(c.sizes))
+		}
+
+		next := 0
+		for i, dataSource := range dataSources {
+			if want := PartDataSourceID("sub-1234", i); dataSource.Id != want {
+				t.Errorf("%s: chunk %d has data source %q, want %q", c.name, i, dataSource.Id, want)
+			}
+			asset := dataSource.Assets[0]
+			if asset.AssetIdentifier.ProviderId != "i-0123" {
+				t.Errorf("%s: chunk %d is for %q, want i-0123", c.name, i, asset.AssetIdentifier.ProviderId)
+			}
+			if len(asset.VulnerabilityFindings) != c.sizes[i] {
+				t.Errorf("%s: chunk %d has %d findings, want %d", c.name, i, len(asset.VulnerabilityFindings), c.sizes[i])
+			}
+			// Findings are split in ID order
+			for _, finding := range asset.VulnerabilityFindings {
+				if want := fmt.Sprintf("WIZCLI-%03d", next); finding.Id != want {
+					t.Errorf("%s: chunk %d holds %s, want %s", c.name, i, finding.Id, want)
+				}
+				next++
+			}
+		}
+	}
+}
+
+func TestSplitIntegrationDataByteLimit(t *testing.T) {
+	data := chunkData(40)
+	whole, err := MarshalPayload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxBytes := len(whole) / 3
+
+	chunks, dataParts, err := SplitIntegrationData(data, 0, maxBytes, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 3 || dataParts != len(chunks) {
+		t.Fatalf("%d chunks with %d holding data, want at least 3, all with data", len(chunks), dataParts)
+	}
+	total := 0
+	for i, chunk := range chunks {
+		if len(chunk) > maxBytes {
+			t.Errorf("chunk %d encodes to %d bytes, above the %d byte limit", i, len(chunk), maxBytes)
+		}
+	}
+	for _, dataSource := range decodeChunks(t, chunks) {
+		total += len(dataSource.Assets[0].VulnerabilityFindings)
+	}
+	if total != 40 {
+		t.Errorf("chunks hold %d findings, want 40", total)
+	}
+}
+
+func TestSplitBySize(t *testing.T) {
+	data := chunkData(1)
+	dataSource := data.DataSources[0]
+	asset := dataSource.Assets[0]
+
+	// A finding larger than the limit is kept on its own
+	asset.VulnerabilityFindings[0].Description = strings.Repeat("x", 4096)
+	parts, err := splitBySize(data, dataSource, asset, asset.VulnerabilityFindings, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || len(parts[0].VulnerabilityFindings) != 1 {
+		t.Fatalf("oversized finding split into %d parts, want it alone in 1", len(parts))
+	}
+
+	// Parts are measured under the longest part ID, so numbering never pushes one over the limit
+	data = chunkData(8)
+	dataSource = data.DataSources[0]
+	asset = dataSource.Assets[0]
+	half := asset
+	half.VulnerabilityFindings = asset.VulnerabilityFindings[:4]
+	longest, err := encodePart(data, dataSource, math.MaxInt32, half)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err = splitBySize(data, dataSource, asset, asset.VulnerabilityFindings, len(longest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("split into %d parts, want 2", len(parts))
+	}
+	for i, part := range parts {
+		encoded, err := encodePart(data, dataSource, i, part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(encoded) > len(longest) {
+			t.Errorf("part %d encodes to %d bytes, above the %d byte limit", i, len(encoded), len(longest))
+		}
+	}
+}
@@ -7,10 +7,9 @@ import (
 	"strings"
 	"time"
 
+	"wizscan/pkg/logger"
 	"wizscan/pkg/wizapi"
 	"wizscan/pkg/wizcli"
-
-	"github.com/google/uuid"
 )
 
 type IntegrationData struct {
@@ -47,153 +46,253 @@ type VulnerabilityFinding struct {
 	Remediation             string `json:"remediation"`
 	ValidatedAtRuntime      bool   `json:"validatedAtRuntime"`
 	Description             string `json:"description"`
+
+	// Local details used by wizscan, not part of the upload
+	Path              string              `json:"-"` // Location of the vulnerable component on the host, if known
+	Locations         []string            `json:"-"` // Distinct paths of the duplicates merged into this finding
+	VendorSeverity    string              `json:"-"` // Severity as reported by the vendor, before mapping
+	FixVerified       bool                `json:"-"` // The installed version was verified to be below FixedVersion
+	Inconsistent      bool                `json:"-"` // The installed version looks at or above FixedVersion, so wizcli may be wrong
+	RemediationTarget string              `json:"-"` // Lowest version fixing every advisory on the component, if any
+	Guidance          RemediationGuidance `json:"-"` // How to upgrade the component in its ecosystem
+	Intel             ThreatIntel         `json:"-"` // Exploitability signals reported by wizcli
+	RiskScore         float64             `json:"-"` // Local priority from 0 to 100, see RiskScore
 }
 
-func CompareVulnerabilities(scanResult wizcli.AggregatedScanResults, knownVulns []wizapi.VulnerabilityNode, externalId string) (Asset, error) {
+// Options controls how CompareVulnerabilities builds findings.
+type Options struct {
+	FindingIDScheme string      // FindingIDSchemeV1 or FindingIDSchemeLegacy, defaults to FindingIDSchemeLegacy
+	RiskWeights     RiskWeights // Weights of the risk score, DefaultRiskWeights when zero
+	SeverityMap     SeverityMap // Vendor severity mapping, DefaultSeverityMap when nil
+
+	DropInconsistent bool // Skip library results whose installed version looks at or above the fixed version instead of flagging them
+}
+
+// CompareVulnerabilities turns the scan results into findings to upload, leaving out the ones the
+// Wiz disk scanner already reports. It also returns a decision for every scanned vulnerability
+// explaining the verdict reached for it.
+func CompareVulnerabilities(scanResult wizcli.AggregatedScanResults, knownVulns []wizapi.VulnerabilityNode, externalId string, opts Options) (Asset, []Decision, error) {
 
 	// Instantiate assetVulns with an empty slice of VulnerabilityFinding
 	assetVulns := Asset{
 		VulnerabilityFindings: make([]VulnerabilityFinding, 0),
 	}
+	decisions := make([]Decision, 0)
+
+	// Index the known vulnerabilities once so each scanned finding is a map lookup
+	index := newKnownVulnIndex(knownVulns)
+	ids := newFindingIDAllocator(opts.FindingIDScheme, externalId, knownVulns)
+	if opts.RiskWeights.total() == 0 {
+		opts.RiskWeights = DefaultRiskWeights
+	}
+	severities := newSeverityNormalizer(opts.SeverityMap)
 
 	for _, lib := range scanResult.Libraries {
+		ecosystem := inferEcosystem(lib.Name, lib.Path, lib.DetectionMethod)
+		target := remediationTarget(lib, ecosystem)
+
 		for _, vuln := range lib.Vulnerabilities {
-			// Then, compare for known vulnerabilities
-			ignoreVuln := false
-			newUUID := uuid.New()
-			id := newUUID.String()
-			vulnCompare := ""
-			path := ""
-			var err error
-			for _, kv := range knownVulns {
-				path, err = extractPath(kv.Description)
-				if err != nil {
-					path = ""
-				}
-				//if !strings.HasPrefix(kv.ID, "WIZCLI") {
-				if kv.DataSourceName == "" {
-					// We are now comparing vulns found by Wiz disk scanner
-					if vuln.Name == kv.Name && lib.Name == kv.DetailedName && vuln.FixedVersion == kv.FixedVersion && lib.DetectionMethod == kv.DetectionMethod && lib.Path == path {
-						// Match says it's an existing vuln from Wiz disk scanner so, ignore
-						vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tLibrary: %s\n\tPath: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Name, kv.ID, "Library", lib.Name, lib.Path, lib.Version, "Ignore")
-						id = kv.ID
-						ignoreVuln = true
-					}
-					//} else if strings.HasPrefix(kv.ID, "WIZCLI") {
-				} else if kv.DataSourceName == "WizCLI" {
-					// We are now comparing vulns found by wizcli scanner
-					if vuln.Name == kv.Name && lib.Name == kv.DetailedName && lib.DetectionMethod == kv.DetectionMethod {
-						vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tLibrary: %s\n\tPath: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Name, kv.ID, "Library", lib.Name, lib.Path, lib.Version, "Keep")
-						//id = kv.ID
-						id = fmt.Sprintf("%s-%s-%s", externalId, vuln.Name, lib.Name)
-					}
-				}
-				// If vulnCompare is empty and ignoreVuln is set to false, then we need to add Wiz Platform
-				if vulnCompare == "" && !ignoreVuln {
-					id = fmt.Sprintf("%s-%s-%s", externalId, vuln.Name, lib.Name)
-					vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tLibrary: %s\n\tPath: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Name, id, "Library", lib.Name, lib.Path, lib.Version, "Add")
-				}
+			key := libraryKey(vuln.Name, lib.Name, lib.DetectionMethod, lib.Path, vuln.FixedVersion)
+			decision := Decision{
+				Kind:         "Library",
+				CVE:          vuln.Name,
+				Component:    lib.Name,
+				Version:      lib.Version,
+				Path:         lib.Path,
+				FixedVersion: vuln.FixedVersion,
 			}
-			//fmt.Println(vulnCompare)
-			if !ignoreVuln {
-				// If ignoreVuln is set to false, then we will want to update the Wiz graph
-				description := ""
-				if lib.Path == "" {
-					description = fmt.Sprintf("The library `%s` version `%s` is vulnerable to `%s`", lib.Name, lib.Version, vuln.Name)
-				} else {
-					description = fmt.Sprintf("The library `%s` version `%s` located at `%s` is vulnerable to `%s`", lib.Name, lib.Version, lib.Path, vuln.Name)
-				}
-				if vuln.FixedVersion != "" {
-					description += fmt.Sprintf(", which exists in versions less than `%s`", vuln.FixedVersion)
-				}
-				description += fmt.Sprintf(".\nThe vulnerability was found at `%s` with vendor severity of: `%s`.\n", vuln.Source, vuln.Severity)
-				if vuln.FixedVersion != "" {
-					description += fmt.Sprintf("The vulnerability can be remediated by updating the library to version `%s` or higher.", vuln.FixedVersion)
-				} else {
-					description += "At this time there is not a fix for this vulnerability."
-				}
-				normalizedSeverity := normalizeAndValidateSeverity(vuln.Severity)
-				vulnerability := VulnerabilityFinding{
-					Id:                      id,
-					Name:                    vuln.Name,
-					DetailedName:            lib.Name,
-					ExternalDetectionSource: "Library",
-					Severity:                normalizedSeverity,
-					ExternalFindingLink:     vuln.Source,
-					Version:                 lib.Version,
-					Source:                  "WizCLI",
-					FixedVersion:            vuln.FixedVersion,
-					Remediation:             vuln.FixedVersion,
-					ValidatedAtRuntime:      false,
-					Description:             description,
+
+			// Match says it's an existing vuln from Wiz disk scanner so, ignore
+			if kv, found := index.nativeLibraries[key]; found {
+				decision.Verdict = VerdictIgnore
+				decision.Reason = nativeReason(kv)
+				decision.Match = newDecisionMatch(key, kv)
+				decisions = append(decisions, decision)
+				continue
+			}
+
+			// Respect ignore rules set in Wiz on another finding for the same vulnerability
+			if kv, found := index.ignoredLibraries[ignoredLibraryKey(vuln.Name, lib.Name, lib.Path)]; found {
+				decision.Verdict = VerdictIgnore
+				decision.Reason = fmt.Sprintf("Ignored in Wiz by %s", ignoredBy(kv))
+				decision.Match = newDecisionMatch(key, kv)
+				decisions = append(decisions, decision)
+				continue
+			}
+
+			// Flag results where the installed version seems to contain the fix already. The
+			// ecosystem is guessed, so they are only dropped when asked to
+			applicability := checkFixApplicability(ecosystem, lib.Version, vuln.FixedVersion)
+			inconsistent := applicability == fixAlreadyInstalled
+			if inconsistent && opts.DropInconsistent {
+				logger.Log.Warnf("Skipping %s in %s: installed version %s is not below fixed version %s (%s)", vuln.Name, lib.Name, lib.Version, vuln.FixedVersion, lib.Path)
+				decision.Verdict = VerdictSkip
+				decision.Reason = "Installed version is not below the fixed version"
+				decisions = append(decisions, decision)
+				continue
+			} else if inconsistent {
+				logger.Log.Warnf("Flagging %s in %s: installed version %s is not below fixed version %s (%s)", vuln.Name, lib.Name, lib.Version, vuln.FixedVersion, lib.Path)
+			}
+
+			previousID := ""
+			kv, previouslyUploaded := index.wizcliLibraries[presenceKey(vuln.Name, lib.Name, lib.DetectionMethod)]
+			if previouslyUploaded {
+				previousID = kv.ID
+			}
+			id := ids.libraryID(vuln.Name, lib.Name, lib.Path, lib.Version, vuln.FixedVersion, previousID)
+
+			decision.FindingID = id
+			if previouslyUploaded {
+				decision.Verdict, decision.Reason = uploadedVerdict(kv)
+				decision.Match = newDecisionMatch(key, kv)
+			} else {
+				kv, found := index.candidates[candidateKey(vuln.Name, lib.Name)]
+				decision.Verdict = VerdictAdd
+				decision.Reason = newFindingReason(kv, found)
+				if found {
+					decision.Match = newDecisionMatch(key, kv)
 				}
-				assetVulns.VulnerabilityFindings = append(assetVulns.VulnerabilityFindings, vulnerability)
 			}
+
+			// Remediate to the version that fixes every advisory on the library when it is known
+			remediationTarget := vuln.FixedVersion
+			if target != "" {
+				remediationTarget = target
+			}
+
+			guidance := newRemediationGuidance(ecosystem, lib.Name, lib.Path, remediationTarget)
+			if guidance.Command != "" {
+				decision.Remediation = &guidance
+			}
+			if inconsistent {
+				decision.Inconsistent = true
+				decision.Reason += "; installed version does not look below the fixed version"
+			}
+
+			// Description and remediation text are rendered from templates once the findings are final
+			normalizedSeverity := severities.normalize(vuln.Severity, vuln.Score)
+			vulnerability := VulnerabilityFinding{
+				Id:                      id,
+				Name:                    vuln.Name,
+				DetailedName:            lib.Name,
+				ExternalDetectionSource: "Library",
+				Severity:                normalizedSeverity,
+				ExternalFindingLink:     vuln.Source,
+				Version:                 lib.Version,
+				Source:                  "WizCLI",
+				FixedVersion:            vuln.FixedVersion,
+				ValidatedAtRuntime:      false,
+				Path:                    lib.Path,
+				VendorSeverity:          vuln.Severity,
+				FixVerified:             applicability == fixApplies,
+				Inconsistent:            inconsistent,
+				RemediationTarget:       remediationTarget,
+				Guidance:                guidance,
+				Intel:                   newThreatIntel(vuln),
+			}
+			vulnerability.RiskScore = RiskScore(vulnerability, opts.RiskWeights)
+			decision.RiskScore = vulnerability.RiskScore
+			decisions = append(decisions, decision)
+			assetVulns.VulnerabilityFindings = append(assetVulns.VulnerabilityFindings, vulnerability)
 		}
 	}
 
 	for _, app := range scanResult.Applications {
 		for _, vuln := range app.Vulnerabilities {
-			// Then, compare for known vulnerabilities
-			ignoreVuln := false
-			newUUID := uuid.New()
-			id := "WIZCLI-" + newUUID.String()
-			vulnCompare := ""
-			path := ""
-			var err error
-			for _, kv := range knownVulns {
-				path, err = extractPath(kv.Description)
-				if err != nil {
-					path = ""
-				}
-				if !strings.HasPrefix(kv.ID, "WIZCLI") {
-					// We are now comparing vulns found by Wiz disk scanner
-					if vuln.Vulnerability.Name == kv.Name && app.Name == kv.DetailedName && vuln.Vulnerability.FixedVersion == kv.FixedVersion && app.DetectionMethod == kv.DetectionMethod {
-						// Match says it's an existing vuln from Wiz disk scanner so, ignore
-						vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tApplication: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Vulnerability.Name, kv.ID, "Application", app.Name, vuln.Version, "Ignore")
-						id = kv.ID
-						ignoreVuln = true
-					}
-				} else if strings.HasPrefix(kv.ID, "WIZCLI") {
-					// We are now comparing vulns found by wizcli scanner
-					if vuln.Vulnerability.Name == kv.Name && app.Name == kv.DetailedName && vuln.Vulnerability.FixedVersion == kv.FixedVersion && app.DetectionMethod == kv.DetectionMethod {
-						vulnCompare = fmt.Sprintf("EXISTING VULN\n%s found in application %s, in version %s\n\n", vuln.Vulnerability.Name, app.Name, vuln.Version)
-						id = kv.ID
-					}
-				}
-				// If vulnCompare is empty and ignoreVuln is set to false, then we need to add Wiz Platform
-				if vulnCompare == "" && !ignoreVuln {
-					vulnCompare = fmt.Sprintf("%s\n\tID: %s\n\tType: %s\n\tApplication: %s\n\tVersion: %s\n\tVerdict: %s\n\n", vuln.Vulnerability.Name, id, "Application", app.Name, vuln.Version, "Add")
-				}
+			key := applicationKey(vuln.Vulnerability.Name, app.Name, app.DetectionMethod, vuln.Vulnerability.FixedVersion)
+			appPath, _ := vuln.Path.(string)
+			explainKey := key
+			explainKey.Path = appPath
+			decision := Decision{
+				Kind:         "Application",
+				CVE:          vuln.Vulnerability.Name,
+				Component:    app.Name,
+				Version:      vuln.Version,
+				Path:         appPath,
+				FixedVersion: vuln.Vulnerability.FixedVersion,
 			}
 
-			if !ignoreVuln {
-				normalizedSeverity := normalizeAndValidateSeverity(vuln.Vulnerability.Severity)
-				vulnerability := VulnerabilityFinding{
-					Id:                      id,
-					Name:                    vuln.Vulnerability.Name,
-					DetailedName:            app.Name,
-					ExternalDetectionSource: "Application",
-					Severity:                normalizedSeverity,
-					ExternalFindingLink:     vuln.Vulnerability.Source,
-					Version:                 vuln.Version,
-					Source:                  "WizCLI",
-					Remediation:             vuln.Vulnerability.FixedVersion,
-					ValidatedAtRuntime:      false,
-					Description:             path,
+			// Match says it's an existing vuln from Wiz disk scanner so, ignore
+			if kv, found := index.nativeApplications[key]; found {
+				decision.Verdict = VerdictIgnore
+				decision.Reason = nativeReason(kv)
+				decision.Match = newDecisionMatch(explainKey, kv)
+				decisions = append(decisions, decision)
+				continue
+			}
+
+			// Respect ignore rules set in Wiz on another finding for the same vulnerability. Application
+			// findings are matched without their path, like the disk scanner ones
+			if kv, found := index.ignoredApplications[candidateKey(vuln.Vulnerability.Name, app.Name)]; found {
+				decision.Verdict = VerdictIgnore
+				decision.Reason = fmt.Sprintf("Ignored in Wiz by %s", ignoredBy(kv))
+				decision.Match = newDecisionMatch(explainKey, kv)
+				decisions = append(decisions, decision)
+				continue
+			}
+
+			previousID := ""
+			kv, previouslyUploaded := index.wizcliApplications[key]
+			if previouslyUploaded {
+				previousID = kv.ID
+			}
+			id := ids.applicationID(vuln.Vulnerability.Name, app.Name, appPath, vuln.Version, vuln.Vulnerability.FixedVersion, previousID)
+
+			decision.FindingID = id
+			if previouslyUploaded {
+				decision.Verdict, decision.Reason = uploadedVerdict(kv)
+				decision.Match = newDecisionMatch(explainKey, kv)
+			} else {
+				kv, found := index.candidates[candidateKey(vuln.Vulnerability.Name, app.Name)]
+				decision.Verdict = VerdictAdd
+				decision.Reason = newFindingReason(kv, found)
+				if found {
+					decision.Match = newDecisionMatch(explainKey, kv)
 				}
-				assetVulns.VulnerabilityFindings = append(assetVulns.VulnerabilityFindings, vulnerability)
 			}
+
+			ecosystem := inferEcosystem(app.Name, appPath, app.DetectionMethod)
+			guidance := newRemediationGuidance(ecosystem, app.Name, appPath, vuln.Vulnerability.FixedVersion)
+			if guidance.Command != "" {
+				decision.Remediation = &guidance
+			}
+
+			normalizedSeverity := severities.normalize(vuln.Vulnerability.Severity, vuln.Vulnerability.Score)
+			vulnerability := VulnerabilityFinding{
+				Id:                      id,
+				Name:                    vuln.Vulnerability.Name,
+				DetailedName:            app.Name,
+				ExternalDetectionSource: "Application",
+				Severity:                normalizedSeverity,
+				ExternalFindingLink:     vuln.Vulnerability.Source,
+				Version:                 vuln.Version,
+				Source:                  "WizCLI",
+				FixedVersion:            vuln.Vulnerability.FixedVersion,
+				ValidatedAtRuntime:      false,
+				Path:                    appPath,
+				VendorSeverity:          vuln.Vulnerability.Severity,
+				RemediationTarget:       vuln.Vulnerability.FixedVersion,
+				Guidance:                guidance,
+				Intel:                   newThreatIntel(vuln.Vulnerability),
+			}
+			vulnerability.RiskScore = RiskScore(vulnerability, opts.RiskWeights)
+			decision.RiskScore = vulnerability.RiskScore
+			decisions = append(decisions, decision)
+			assetVulns.VulnerabilityFindings = append(assetVulns.VulnerabilityFindings, vulnerability)
 		}
 	}
 
-	return assetVulns, nil
+	severities.warnUnmapped()
+
+	return assetVulns, decisions, nil
 
 }
 
+// locatedAtPattern pulls the library path out of a Wiz disk scanner finding description
+var locatedAtPattern = regexp.MustCompile(`located at (.*?) and is vulnerable to`)
+
 func extractPath(str string) (string, error) {
-	re := regexp.MustCompile(`located at (.*?) and is vulnerable to`)
-	matches := re.FindStringSubmatch(str)
+	matches := locatedAtPattern.FindStringSubmatch(str)
 
 	if len(matches) > 1 {
 		trimmedPath := strings.Trim(matches[1], "`") // Remove backticks from start and end
@@ -203,26 +302,3 @@ func extractPath(str string) (string, error) {
 	// Return an empty string and an error if the path could not be extracted
 	return "", errors.New("path not found in the string")
 }
-
-// normalizeAndValidateSeverity takes a string, normalizes it to have the first letter uppercase
-// and the rest lowercase, and then checks if it matches allowed severity levels.
-func normalizeAndValidateSeverity(input string) string {
-	// Normalize the string: lowercase with an uppercase first letter
-	if len(input) == 0 {
-		return "None" // Return "None" if the input is empty
-	}
-	normalized := strings.ToUpper(input[:1]) + strings.ToLower(input[1:])
-
-	// List of allowed severity levels
-	allowedSeverities := []string{"None", "Low", "Medium", "High", "Critical"}
-
-	// Check if the normalized string is in the list of allowed severities
-	for _, severity := range allowedSeverities {
-		if normalized == severity {
-			return normalized
-		}
-	}
-
-	// Default to "None" if no match is found
-	return "None"
-}
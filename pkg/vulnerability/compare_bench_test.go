@@ -0,0 +1,138 @@
+package vulnerability
+
+import (
+	"fmt"
+	"testing"
+
+	"wizscan/pkg/logger"
+	"wizscan/pkg/wizapi"
+	"wizscan/pkg/wizcli"
+
+	"github.com/sirupsen/logrus"
+)
+
+// benchmarkSizes are the numbers of scanned libraries the benchmarks run with. Each library carries
+// two vulnerabilities and each application one, so the largest size compares 100k+ scanned
+// vulnerabilities with as many known findings.
+var benchmarkSizes = []int{1000, 10000, 50000}
+
+// syntheticScan builds a scan of n libraries and n/10 applications with two vulnerabilities per
+// library, together with a known set in which a third of the library vulnerabilities are reported
+// by the Wiz disk scanner, a third were uploaded by wizscan before and the rest are new. Unrelated
+// known findings pad the set to the size of a busy host.
+func syntheticScan(n int) (wizcli.AggregatedScanResults, []wizapi.VulnerabilityNode) {
+	var scan wizcli.AggregatedScanResults
+	known := make([]wizapi.VulnerabilityNode, 0, 3*n)
+
+	for i := 0; i < n; i++ {
+		lib := wizcli.Library{
+			Name:            fmt.Sprintf("lib-%d", i),
+			Version:         "1.0.0",
+			Path:            fmt.Sprintf("/opt/app-%d/node_modules/lib-%d/package.json", i%50, i),
+			DetectionMethod: "PACKAGE",
+		}
+		for j := 0; j < 2; j++ {
+			cve := fmt.Sprintf("CVE-2024-%05d", i*2+j)
+			lib.Vulnerabilities = append(lib.Vulnerabilities, wizcli.Vulnerability{
+				Name:         cve,
+				Severity:     "HIGH",
+				FixedVersion: "1.2.0",
+				Score:        7.5,
+			})
+			switch (i*2 + j) % 3 {
+			case 0:
+				known = append(known, wizapi.VulnerabilityNode{
+					ID:              fmt.Sprintf("native-%d-%d", i, j),
+					Name:            cve,
+					DetailedName:    lib.Name,
+					DetectionMethod: lib.DetectionMethod,
+					LocationPath:    lib.Path,
+					FixedVersion:    "1.2.0",
+					Status:          "OPEN",
+				})
+			case 1:
+				known = append(known, wizapi.VulnerabilityNode{
+					ID:              fmt.Sprintf("WIZCLI-%d-%d", i, j),
+					Name:            cve,
+					DetailedName:    lib.Name,
+					DetectionMethod: lib.DetectionMethod,
+					DataSourceName:  "WizCLI",
+					Status:          "OPEN",
+				})
+			}
+		}
+		scan.Libraries = append(scan.Libraries, lib)
+	}
+
+	for i := 0; i < n/10; i++ {
+		scan.Applications = append(scan.Applications, wizcli.Applications{
+			Name:            fmt.Sprintf("app-%d", i),
+			DetectionMethod: "FILE",
+			Vulnerabilities: []wizcli.VulnerabilityDetail{{
+				Path:    fmt.Sprintf("/usr/local/bin/app-%d", i),
+				Version: "2.0",
+				Vulnerability: wizcli.Vulnerability{
+					Name:         fmt.Sprintf("CVE-2023-%05d", i),
+					Severity:     "MEDIUM",
+					FixedVersion: "2.1",
+				},
+			}},
+		})
+	}
+
+	for i := len(known); i < 3*n; i++ {
+		known = append(known, wizapi.VulnerabilityNode{
+			ID:              fmt.Sprintf("other-%d", i),
+			Name:            fmt.Sprintf("CVE-2022-%05d", i),
+			DetailedName:    fmt.Sprintf("pkg-%d", i),
+			DetectionMethod: "OS_PACKAGE",
+			Status:          "OPEN",
+		})
+	}
+
+	return scan, known
+}
+
+func BenchmarkCompareVulnerabilities(b *testing.B) {
+	logger.Init(logrus.ErrorLevel)
+	for _, n := range benchmarkSizes {
+		scan, known := syntheticScan(n)
+		b.Run(fmt.Sprintf("libraries=%d/known=%d", n, len(known)), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := CompareVulnerabilities(scan, known, "i-0123456789", Options{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNewKnownVulnIndex(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		_, known := syntheticScan(n)
+		b.Run(fmt.Sprintf("known=%d", len(known)), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				newKnownVulnIndex(known)
+			}
+		})
+	}
+}
+
+func BenchmarkFindResolvedVulnerabilities(b *testing.B) {
+	logger.Init(logrus.ErrorLevel)
+	for _, n := range benchmarkSizes {
+		scan, known := syntheticScan(n)
+		asset, _, err := CompareVulnerabilities(scan, known, "i-0123456789", Options{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("libraries=%d/known=%d", n, len(known)), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				FindResolvedVulnerabilities(asset, known)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package vulnerability
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DataSourceIDData is the data model the data source ID template is executed against, e.g.
+// "{{.SubscriptionID}}-{{.Hostname}}".
+type DataSourceIDData struct {
+	Hostname       string // Name of the scanned host
+	RunID          string // Random ID of this wizscan run
+	SubscriptionID string // Scan subscription ID
+	ProviderID     string // Provider ID of the scanned asset
+	CloudType      string // Cloud platform of the scanned asset
+}
+
+// RenderDataSourceID executes the data source ID template.
+func RenderDataSourceID(text string, data DataSourceIDData) (string, error) {
+	tmpl, err := template.New("dataSourceId").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse data source ID template: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render data source ID template: %w", err)
+	}
+
+	id := strings.TrimSpace(sb.String())
+	if id == "" {
+		return "", fmt.Errorf("data source ID template %q rendered an empty ID", text)
+	}
+	return id, nil
+}
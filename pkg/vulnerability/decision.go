@@ -0,0 +1,150 @@
+package vulnerability
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Verdict is the outcome of comparing a scanned vulnerability with the known Wiz findings.
+type Verdict string
+
+const (
+	VerdictAdd      Verdict = "Add"      // New finding, uploaded
+	VerdictKeep     Verdict = "Keep"     // Previously uploaded wizcli finding, uploaded again
+	VerdictIgnore   Verdict = "Ignore"   // Already reported by the Wiz disk scanner, not uploaded
+	VerdictSkip     Verdict = "Skip"     // Inconsistent scan result dropped on request, not uploaded
+	VerdictSuppress Verdict = "Suppress" // Covered by a suppression rule, not uploaded
+	VerdictFilter   Verdict = "Filter"   // Risk score below the upload threshold, not uploaded
+	VerdictMerge    Verdict = "Merge"    // Duplicate merged into another finding, uploaded as part of it
+)
+
+// Decision explains the verdict reached for one scanned vulnerability.
+type Decision struct {
+	Kind         string               `json:"kind"` // Library or Application
+	CVE          string               `json:"cve"`
+	Component    string               `json:"component"`
+	Version      string               `json:"version"`
+	Path         string               `json:"path,omitempty"`
+	FixedVersion string               `json:"fixedVersion,omitempty"`
+	FindingID    string               `json:"findingId,omitempty"` // ID of the uploaded finding, if any
+	RiskScore    float64              `json:"riskScore,omitempty"`
+	Remediation  *RemediationGuidance `json:"remediation,omitempty"`  // How to upgrade the component, if known
+	Inconsistent bool                 `json:"inconsistent,omitempty"` // Installed version looks at or above the fixed version
+	Verdict      Verdict              `json:"verdict"`
+	Reason       string               `json:"reason"`
+	Match        *DecisionMatch       `json:"match,omitempty"` // Closest known Wiz finding, if any
+}
+
+// DecisionMatch describes the known Wiz finding a scanned vulnerability was compared with.
+type DecisionMatch struct {
+	ID              string   `json:"id"`
+	DataSource      string   `json:"dataSource"`
+	MatchedFields   []string `json:"matchedFields"`
+	DifferingFields []string `json:"differingFields"`
+	Status          string   `json:"status,omitempty"`      // Status of the finding in Wiz
+	ResolvedAt      string   `json:"resolvedAt,omitempty"`  // When Wiz resolved the finding, if it did
+	IgnoreRules     []string `json:"ignoreRules,omitempty"` // Wiz ignore rules matching the finding
+}
+
+// newDecisionMatch compares the fields of a scanned vulnerability with a known finding.
+func newDecisionMatch(scanned matchKey, known indexedVuln) *DecisionMatch {
+	match := &DecisionMatch{
+		ID:              known.ID,
+		DataSource:      known.DataSourceName,
+		MatchedFields:   make([]string, 0),
+		DifferingFields: make([]string, 0),
+		Status:          known.Status,
+		ResolvedAt:      known.ResolvedAt,
+		IgnoreRules:     known.IgnoreRuleIDs(),
+	}
+	if match.DataSource == "" {
+		match.DataSource = "Wiz"
+	}
+
+	fields := []struct {
+		name           string
+		scanned, known string
+	}{
+		{"name", scanned.CVE, known.Name},
+		{"detailedName", scanned.DetailedName, known.DetailedName},
+		{"detectionMethod", scanned.DetectionMethod, known.DetectionMethod},
+		{"path", scanned.Path, known.path},
+		{"fixedVersion", scanned.FixedVersion, known.FixedVersion},
+	}
+	for _, field := range fields {
+		if field.scanned == field.known {
+			match.MatchedFields = append(match.MatchedFields, field.name)
+		} else {
+			match.DifferingFields = append(match.DifferingFields, field.name)
+		}
+	}
+
+	return match
+}
+
+// MarkRemoved switches the verdict of the decisions whose findings were removed from the upload
+// by a later stage, such as suppression rules or risk filtering.
+func MarkRemoved(decisions []Decision, removed []VulnerabilityFinding, verdict Verdict, reason string) {
+	ids := make(map[string]bool, len(removed))
+	for _, finding := range removed {
+		ids[finding.Id] = true
+	}
+	for i := range decisions {
+		if ids[decisions[i].FindingID] {
+			decisions[i].Verdict = verdict
+			decisions[i].Reason = reason
+		}
+	}
+}
+
+// WriteDecisionsJSON writes the decisions as an indented JSON array.
+func WriteDecisionsJSON(w io.Writer, decisions []Decision) error {
+	data, err := json.MarshalIndent(decisions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal decisions: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// WriteDecisionsTable writes the decisions as an aligned, human readable table.
+func WriteDecisionsTable(w io.Writer, decisions []Decision) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERDICT\tRISK\tKIND\tCVE\tCOMPONENT\tVERSION\tPATH\tMATCHED\tDIFFERING\tUPGRADE\tREASON")
+	for _, d := range decisions {
+		matched, differing := "-", "-"
+		if d.Match != nil {
+			matched = fmt.Sprintf("%s (%s)", d.Match.ID, d.Match.DataSource)
+			if d.Match.Status != "" && d.Match.Status != "OPEN" {
+				matched = fmt.Sprintf("%s (%s, %s)", d.Match.ID, d.Match.DataSource, d.Match.Status)
+			}
+			if len(d.Match.DifferingFields) > 0 {
+				differing = strings.Join(d.Match.DifferingFields, ",")
+			}
+		}
+		risk := "-"
+		if d.FindingID != "" {
+			risk = fmt.Sprintf("%.1f", d.RiskScore)
+		}
+		upgrade := "-"
+		if d.Remediation != nil {
+			upgrade = d.Remediation.Command
+			if d.Remediation.Constraint != "" {
+				upgrade = fmt.Sprintf("%s, then %s", d.Remediation.Constraint, upgrade)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.Verdict, risk, d.Kind, d.CVE, d.Component, orDash(d.Version), orDash(d.Path), matched, differing, upgrade, d.Reason)
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package vulnerability
+
+import (
+	"fmt"
+)
+
+// dedupKey identifies findings that describe the same vulnerable component, whichever scan root,
+// drive or result section (libraries or applications) they were reported from.
+type dedupKey struct {
+	CVE       string
+	Component string
+	Version   string
+	Path      string
+}
+
+// DeduplicateFindings merges findings with the same CVE, component, version and normalized path,
+// keeping the first one and recording the distinct paths of the others in its Locations. It
+// returns the IDs of the collapsed findings, mapped to the ID of the finding they were merged into.
+func DeduplicateFindings(asset *Asset) map[string]string {
+	seen := make(map[dedupKey]int, len(asset.VulnerabilityFindings))
+	merged := asset.VulnerabilityFindings[:0]
+	collapsed := make(map[string]string)
+
+	for _, finding := range asset.VulnerabilityFindings {
+		key := dedupKey{
+			CVE:       finding.Name,
+			Component: finding.DetailedName,
+			Version:   finding.Version,
+			Path:      normalizeFindingPath(finding.Path),
+		}
+
+		i, duplicate := seen[key]
+		if !duplicate {
+			finding.Locations = addLocation(nil, finding.Path)
+			seen[key] = len(merged)
+			merged = append(merged, finding)
+			continue
+		}
+
+		merged[i].Locations = addLocation(merged[i].Locations, finding.Path)
+		collapsed[finding.Id] = merged[i].Id
+	}
+	asset.VulnerabilityFindings = merged
+
+	return collapsed
+}
+
+// addLocation appends a path to the list unless it is empty or already present.
+func addLocation(locations []string, path string) []string {
+	if path == "" {
+		return locations
+	}
+	for _, location := range locations {
+		if location == path {
+			return locations
+		}
+	}
+	return append(locations, path)
+}
+
+// MarkMerged gives the decisions of collapsed findings the Merge verdict, pointing them at the
+// uploaded finding they were merged into.
+func MarkMerged(decisions []Decision, merged map[string]string) {
+	for i := range decisions {
+		into, ok := merged[decisions[i].FindingID]
+		if !ok {
+			continue
+		}
+		decisions[i].Verdict = VerdictMerge
+		decisions[i].Reason = fmt.Sprintf("Duplicate of finding %s, uploaded with its location", into)
+		decisions[i].FindingID = into
+	}
+}
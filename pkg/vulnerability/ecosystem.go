@@ -0,0 +1,108 @@
+package vulnerability
+
+import (
+	"path"
+	"strings"
+
+	"wizscan/pkg/version"
+)
+
+// Ecosystem is the package ecosystem a library belongs to.
+type Ecosystem string
+
+const (
+	EcosystemUnknown Ecosystem = ""
+	EcosystemNpm     Ecosystem = "npm"
+	EcosystemPip     Ecosystem = "pip"
+	EcosystemMaven   Ecosystem = "maven"
+	EcosystemGo      Ecosystem = "go"
+	EcosystemNuGet   Ecosystem = "nuget"
+	EcosystemGem     Ecosystem = "gem"
+	EcosystemDebian  Ecosystem = "deb"
+	EcosystemRPM     Ecosystem = "rpm"
+)
+
+// versionSchemes maps each ecosystem onto the ordering its versions follow.
+var versionSchemes = map[Ecosystem]version.Scheme{
+	EcosystemNpm:    version.Semver,
+	EcosystemPip:    version.PEP440,
+	EcosystemMaven:  version.Maven,
+	EcosystemGo:     version.Semver,
+	EcosystemNuGet:  version.Semver,
+	EcosystemGem:    version.Semver,
+	EcosystemDebian: version.Debian,
+	EcosystemRPM:    version.RPM,
+}
+
+// VersionScheme returns the version ordering of the ecosystem, if it is known.
+func (e Ecosystem) VersionScheme() (version.Scheme, bool) {
+	scheme, ok := versionSchemes[e]
+	return scheme, ok
+}
+
+// detectionMethodEcosystems maps words found in wizcli detection methods onto ecosystems
+var detectionMethodEcosystems = map[string]Ecosystem{
+	"npm":    EcosystemNpm,
+	"node":   EcosystemNpm,
+	"yarn":   EcosystemNpm,
+	"pip":    EcosystemPip,
+	"python": EcosystemPip,
+	"maven":  EcosystemMaven,
+	"java":   EcosystemMaven,
+	"jar":    EcosystemMaven,
+	"go":     EcosystemGo,
+	"golang": EcosystemGo,
+	"nuget":  EcosystemNuGet,
+	"dotnet": EcosystemNuGet,
+	"gem":    EcosystemGem,
+	"ruby":   EcosystemGem,
+}
+
+// inferEcosystem guesses the ecosystem of a library from where it was found, how wizcli detected
+// it and how it is named.
+func inferEcosystem(name, componentPath, detectionMethod string) Ecosystem {
+	p := strings.ToLower(strings.ReplaceAll(componentPath, "\\", "/"))
+	base := path.Base(p)
+
+	switch {
+	case strings.Contains(p, "/node_modules/") || base == "package.json" || base == "package-lock.json" || base == "yarn.lock" || base == "pnpm-lock.yaml":
+		return EcosystemNpm
+	case strings.Contains(p, "/site-packages/") || strings.Contains(p, "/dist-packages/") || strings.Contains(p, ".dist-info") || strings.Contains(p, ".egg-info") ||
+		base == "requirements.txt" || base == "poetry.lock" || base == "pipfile.lock" || base == "pyproject.toml":
+		return EcosystemPip
+	case strings.HasSuffix(base, ".jar") || strings.HasSuffix(base, ".war") || strings.HasSuffix(base, ".ear") || base == "pom.xml" || strings.Contains(p, "/.m2/"):
+		return EcosystemMaven
+	case base == "go.mod" || base == "go.sum":
+		return EcosystemGo
+	case strings.HasSuffix(base, ".deps.json") || strings.HasSuffix(base, ".nupkg") || strings.HasSuffix(base, ".csproj") || base == "packages.config" || base == "packages.lock.json":
+		return EcosystemNuGet
+	case strings.Contains(p, "/gems/") || base == "gemfile.lock" || strings.HasSuffix(base, ".gemspec"):
+		return EcosystemGem
+	case base == "status" && strings.Contains(p, "/dpkg/"):
+		return EcosystemDebian
+	case strings.Contains(p, "/rpm/"):
+		return EcosystemRPM
+	}
+
+	// Detection methods such as GO_BINARY name the ecosystem when the path does not
+	words := strings.FieldsFunc(strings.ToLower(detectionMethod), func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	for _, word := range words {
+		if ecosystem, found := detectionMethodEcosystems[word]; found {
+			return ecosystem
+		}
+	}
+
+	// Fall back on naming conventions when the path gives nothing away
+	switch {
+	case strings.Count(name, ":") == 1:
+		return EcosystemMaven // groupId:artifactId
+	case strings.HasPrefix(name, "github.com/") || strings.HasPrefix(name, "golang.org/") || strings.HasPrefix(name, "go.opentelemetry.io/") || strings.HasPrefix(name, "google.golang.org/") || strings.HasPrefix(name, "gopkg.in/") || name == "stdlib":
+		return EcosystemGo
+	case strings.HasPrefix(name, "@"):
+		return EcosystemNpm // Scoped package
+	}
+
+	return EcosystemUnknown
+}
@@ -0,0 +1,151 @@
+package vulnerability
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"wizscan/pkg/wizapi"
+
+	"github.com/google/uuid"
+)
+
+// Finding ID schemes
+//
+// FindingIDSchemeV1 derives every finding ID from what the finding is about, so the same
+// vulnerability on the same asset keeps its ID from one run to the next and Wiz updates the
+// existing finding instead of creating a new one. The ID is
+//
+//	WIZCLI-<first 32 hex characters of sha256("v1" NUL providerId NUL kind NUL cve NUL component NUL path NUL fixedVersion NUL version)>
+//
+// where kind is "library" or "application", component is the library or application name, path
+// is the normalized location of the component (see normalizeFindingPath) and fixedVersion is the
+// version fixing the CVE. The installed version is only hashed when the path is unknown, so that
+// two installations of an application without a path get distinct IDs while upgrading a component
+// that is still vulnerable keeps its ID. The "WIZCLI-" prefix is what CompareVulnerabilities uses
+// to recognize previously uploaded findings.
+//
+// Since every attribute that can tell two findings apart is hashed, IDs only collide for exact
+// duplicates, which DeduplicateFindings collapses before the upload.
+//
+// A finding matching a known wizcli finding uploaded under a legacy ID keeps that ID under V1, so
+// switching schemes keeps the findings already in Wiz and their triage state; only findings new to
+// Wiz get V1 IDs. A legacy ID is reused for one finding of the upload at most.
+//
+// FindingIDSchemeLegacy reproduces the IDs issued before V1: "<providerId>-<cve>-<library>" for
+// library findings and "WIZCLI-<random uuid>" for new application findings, reusing the ID of a
+// matching known finding when there is one. It is the default.
+const (
+	FindingIDSchemeV1     = "v1"
+	FindingIDSchemeLegacy = "legacy"
+)
+
+// windowsDrivePattern recognizes paths rooted at a drive letter, which are compared case-insensitively
+var windowsDrivePattern = regexp.MustCompile(`^[A-Za-z]:(/|$)`)
+
+// v1FindingIDPattern recognizes IDs issued by FindingIDSchemeV1
+var v1FindingIDPattern = regexp.MustCompile(`^WIZCLI-[0-9a-f]{32}$`)
+
+// findingIDAllocator hands out finding IDs for a single comparison and guarantees they are unique.
+type findingIDAllocator struct {
+	scheme     string
+	externalId string
+	uploaded   map[string]bool // IDs of the findings uploaded by earlier runs
+	issued     map[string]int  // Number of times each base ID has been handed out
+}
+
+func newFindingIDAllocator(scheme, externalId string, knownVulns []wizapi.VulnerabilityNode) *findingIDAllocator {
+	if scheme == "" {
+		scheme = FindingIDSchemeLegacy
+	}
+	uploaded := make(map[string]bool)
+	for _, kv := range knownVulns {
+		if uploadedByWizscan(kv) {
+			uploaded[kv.ID] = true
+		}
+	}
+	return &findingIDAllocator{
+		scheme:     scheme,
+		externalId: externalId,
+		uploaded:   uploaded,
+		issued:     make(map[string]int),
+	}
+}
+
+// libraryID returns the ID for a vulnerability found in a library. previousID is the ID of a
+// matching wizcli finding already known to Wiz, if any, and is only used by the V1 scheme, whose
+// legacy library IDs are derived from the same attributes.
+func (a *findingIDAllocator) libraryID(cve, libraryName, libraryPath, version, fixedVersion, previousID string) string {
+	if a.scheme == FindingIDSchemeLegacy {
+		return a.unique(fmt.Sprintf("%s-%s-%s", a.externalId, cve, libraryName))
+	}
+	return a.stableID(stableFindingID(a.externalId, "library", cve, libraryName, libraryPath, version, fixedVersion), previousID)
+}
+
+// applicationID returns the ID for a vulnerability found in an application. previousID is the ID
+// of a matching wizcli finding already known to Wiz, if any.
+func (a *findingIDAllocator) applicationID(cve, applicationName, applicationPath, version, fixedVersion, previousID string) string {
+	if a.scheme == FindingIDSchemeLegacy {
+		if previousID != "" {
+			return a.unique(previousID)
+		}
+		return a.unique("WIZCLI-" + uuid.New().String())
+	}
+	return a.stableID(stableFindingID(a.externalId, "application", cve, applicationName, applicationPath, version, fixedVersion), previousID)
+}
+
+// stableID returns the V1 ID of a finding, unless it matches a finding uploaded under a legacy ID
+// that no other finding of this upload has taken yet, in which case that ID is kept. A known V1 ID
+// belongs to the installation it was hashed from, so it is never handed to another one.
+func (a *findingIDAllocator) stableID(id, previousID string) string {
+	if !a.uploaded[id] && previousID != "" && !v1FindingIDPattern.MatchString(previousID) && a.issued[previousID] == 0 {
+		return a.unique(previousID)
+	}
+	return a.unique(id)
+}
+
+// unique returns id the first time it is requested and appends a counter on later requests, so a
+// collision never produces two findings with the same ID in one upload. Under V1 this only happens
+// for exact duplicates, whose suffixed copies DeduplicateFindings drops.
+func (a *findingIDAllocator) unique(id string) string {
+	a.issued[id]++
+	if count := a.issued[id]; count > 1 {
+		return fmt.Sprintf("%s-%d", id, count)
+	}
+	return id
+}
+
+// stableFindingID hashes the identifying attributes of a finding into a V1 finding ID.
+func stableFindingID(externalId, kind, cve, component, componentPath, version, fixedVersion string) string {
+	if componentPath != "" {
+		version = "" // The path tells installations apart, so upgrades keep the ID
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		FindingIDSchemeV1,
+		externalId,
+		kind,
+		cve,
+		component,
+		normalizeFindingPath(componentPath),
+		fixedVersion,
+		version,
+	}, "\x00")))
+	return "WIZCLI-" + hex.EncodeToString(sum[:])[:32]
+}
+
+// normalizeFindingPath puts a component path into a canonical form so that cosmetic differences
+// (separators, duplicate or trailing slashes, drive letter case) do not change the finding ID.
+func normalizeFindingPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	p = path.Clean(strings.ReplaceAll(p, "\\", "/"))
+	if windowsDrivePattern.MatchString(p) {
+		// Windows paths are case-insensitive
+		p = strings.ToLower(p)
+	}
+	return p
+}
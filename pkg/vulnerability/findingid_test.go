@@ -0,0 +1,133 @@
+package vulnerability
+
+import (
+	"testing"
+
+	"wizscan/pkg/wizapi"
+)
+
+func TestNormalizeFindingPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"/usr/lib/libssl.so", "/usr/lib/libssl.so"},
+		{"/usr//lib/./libssl.so/", "/usr/lib/libssl.so"},
+		{"/opt/app/../lib/a.jar", "/opt/lib/a.jar"},
+		{`C:\Program Files\App\app.exe`, "c:/program files/app/app.exe"},
+		{`c:\program files\app\APP.EXE`, "c:/program files/app/app.exe"},
+		{"C:", "c:"},
+		{"/Opt/App", "/Opt/App"},
+	}
+	for _, c := range cases {
+		if got := normalizeFindingPath(c.path); got != c.want {
+			t.Errorf("normalizeFindingPath(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestStableFindingID(t *testing.T) {
+	id := func(path, version string) string {
+		return stableFindingID("i-0123", "library", "CVE-2024-0001", "openssl", path, version, "3.0.8")
+	}
+	if !v1FindingIDPattern.MatchString(id("/usr/lib", "3.0.1")) {
+		t.Fatalf("stableFindingID = %q, want the V1 format", id("/usr/lib", "3.0.1"))
+	}
+
+	same := []struct {
+		name string
+		a, b string
+	}{
+		{"separators", id(`C:\Program Files\OpenSSL`, "3.0.1"), id("C:/Program Files/OpenSSL", "3.0.1")},
+		{"drive letter case", id(`C:\Program Files\OpenSSL`, "3.0.1"), id(`c:\program files\openssl`, "3.0.1")},
+		{"duplicate and trailing slashes", id("/usr//lib/", "3.0.1"), id("/usr/lib", "3.0.1")},
+		{"version with a path", id("/usr/lib", "3.0.1"), id("/usr/lib", "3.0.2")},
+	}
+	for _, c := range same {
+		if c.a != c.b {
+			t.Errorf("%s: IDs differ: %s and %s", c.name, c.a, c.b)
+		}
+	}
+
+	different := []struct {
+		name string
+		a, b string
+	}{
+		{"path", id("/usr/lib", "3.0.1"), id("/opt/lib", "3.0.1")},
+		{"unix path case", id("/usr/lib", "3.0.1"), id("/usr/Lib", "3.0.1")},
+		{"version without a path", id("", "3.0.1"), id("", "3.0.2")},
+		{"kind", id("/usr/lib", "3.0.1"), stableFindingID("i-0123", "application", "CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.8")},
+		{"asset", id("/usr/lib", "3.0.1"), stableFindingID("i-4567", "library", "CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.8")},
+		{"fixed version", id("/usr/lib", "3.0.1"), stableFindingID("i-0123", "library", "CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.9")},
+	}
+	for _, c := range different {
+		if c.a == c.b {
+			t.Errorf("%s: IDs are both %s", c.name, c.a)
+		}
+	}
+}
+
+func TestFindingIDAllocatorUnique(t *testing.T) {
+	ids := newFindingIDAllocator(FindingIDSchemeLegacy, "i-0123", nil)
+	want := []string{"i-0123-CVE-2024-0001-openssl", "i-0123-CVE-2024-0001-openssl-2", "i-0123-CVE-2024-0001-openssl-3"}
+	for i, w := range want {
+		if got := ids.libraryID("CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.8", ""); got != w {
+			t.Errorf("request %d: libraryID = %q, want %q", i+1, got, w)
+		}
+	}
+
+	ids = newFindingIDAllocator(FindingIDSchemeV1, "i-0123", nil)
+	first := ids.libraryID("CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.8", "")
+	second := ids.libraryID("CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.8", "")
+	if second != first+"-2" {
+		t.Errorf("duplicate libraryID = %q, want %q", second, first+"-2")
+	}
+}
+
+func TestFindingIDAllocatorV1ReusesLegacyIDs(t *testing.T) {
+	legacyLibrary := "i-0123-CVE-2024-0001-openssl"
+	legacyApplication := "WIZCLI-5f0c3b52-8d8e-4a39-9d37-1f0f3c1b2a11"
+	known := []wizapi.VulnerabilityNode{
+		{ID: legacyLibrary, DataSourceName: "WizCLI"},
+		{ID: legacyApplication, DataSourceName: "WizCLI"},
+	}
+	ids := newFindingIDAllocator(FindingIDSchemeV1, "i-0123", known)
+
+	if got := ids.libraryID("CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.8", legacyLibrary); got != legacyLibrary {
+		t.Errorf("libraryID = %q, want the legacy ID %q", got, legacyLibrary)
+	}
+	if got := ids.applicationID("CVE-2024-0002", "app", "/opt/app", "1.0", "1.1", legacyApplication); got != legacyApplication {
+		t.Errorf("applicationID = %q, want the legacy ID %q", got, legacyApplication)
+	}
+
+	// A second installation matching the same legacy finding gets its own V1 ID
+	second := ids.libraryID("CVE-2024-0001", "openssl", "/opt/lib", "3.0.1", "3.0.8", legacyLibrary)
+	if want := stableFindingID("i-0123", "library", "CVE-2024-0001", "openssl", "/opt/lib", "3.0.1", "3.0.8"); second != want {
+		t.Errorf("second libraryID = %q, want the V1 ID %q", second, want)
+	}
+
+	// A new finding gets a V1 ID
+	if got := ids.libraryID("CVE-2024-0003", "zlib", "/usr/lib", "1.2", "1.3", ""); !v1FindingIDPattern.MatchString(got) {
+		t.Errorf("new libraryID = %q, want a V1 ID", got)
+	}
+}
+
+func TestFindingIDAllocatorV1KeepsKnownV1IDs(t *testing.T) {
+	first := stableFindingID("i-0123", "library", "CVE-2024-0001", "openssl", "/usr/lib", "", "3.0.8")
+	second := stableFindingID("i-0123", "library", "CVE-2024-0001", "openssl", "/opt/lib", "", "3.0.8")
+	known := []wizapi.VulnerabilityNode{
+		{ID: first, DataSourceName: "WizCLI"},
+		{ID: second, DataSourceName: "WizCLI"},
+	}
+	ids := newFindingIDAllocator(FindingIDSchemeV1, "i-0123", known)
+
+	// The index only remembers the first known finding per CVE and component, which must not be
+	// handed to the other installation
+	if got := ids.libraryID("CVE-2024-0001", "openssl", "/opt/lib", "3.0.1", "3.0.8", first); got != second {
+		t.Errorf("libraryID = %q, want its own known V1 ID %q", got, second)
+	}
+	if got := ids.libraryID("CVE-2024-0001", "openssl", "/usr/lib", "3.0.1", "3.0.8", first); got != first {
+		t.Errorf("libraryID = %q, want its own known V1 ID %q", got, first)
+	}
+}
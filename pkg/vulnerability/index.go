@@ -0,0 +1,128 @@
+package vulnerability
+
+import (
+	"strings"
+
+	"wizscan/pkg/wizapi"
+)
+
+// matchKey identifies a vulnerability finding by the fields CompareVulnerabilities matches on.
+// Fields that are not part of a given comparison are left empty.
+type matchKey struct {
+	CVE             string
+	DetailedName    string
+	DetectionMethod string
+	Path            string
+	FixedVersion    string
+}
+
+// indexedVuln is a known vulnerability along with the path of the vulnerable component.
+type indexedVuln struct {
+	wizapi.VulnerabilityNode
+	path string
+}
+
+// knownVulnIndex holds lookup tables over the known Wiz vulnerabilities so that
+// scanned findings can be matched without rescanning the whole list for each one.
+type knownVulnIndex struct {
+	nativeLibraries     map[matchKey]indexedVuln // Wiz disk scanner findings, matched against libraries
+	nativeApplications  map[matchKey]indexedVuln // Wiz disk scanner findings, matched against applications
+	wizcliLibraries     map[matchKey]indexedVuln // Previously uploaded wizcli library findings
+	wizcliApplications  map[matchKey]indexedVuln // Previously uploaded wizcli application findings
+	candidates          map[matchKey]indexedVuln // Any known finding for a CVE and component, used to explain mismatches
+	ignoredLibraries    map[matchKey]indexedVuln // Findings not uploaded by wizscan that are ignored in Wiz, by CVE, component and path
+	ignoredApplications map[matchKey]indexedVuln // The same findings by CVE and component, matched against applications
+}
+
+// libraryKey builds the key a library vulnerability is matched against Wiz disk scanner findings with.
+func libraryKey(cve, libraryName, detectionMethod, path, fixedVersion string) matchKey {
+	return matchKey{
+		CVE:             cve,
+		DetailedName:    libraryName,
+		DetectionMethod: detectionMethod,
+		Path:            path,
+		FixedVersion:    fixedVersion,
+	}
+}
+
+// applicationKey builds the key an application vulnerability is matched against known findings with.
+func applicationKey(cve, applicationName, detectionMethod, fixedVersion string) matchKey {
+	return matchKey{
+		CVE:             cve,
+		DetailedName:    applicationName,
+		DetectionMethod: detectionMethod,
+		FixedVersion:    fixedVersion,
+	}
+}
+
+// candidateKey builds the key known findings about the same CVE and component are grouped under.
+func candidateKey(cve, component string) matchKey {
+	return matchKey{CVE: cve, DetailedName: component}
+}
+
+// ignoredLibraryKey builds the key a library vulnerability is matched against ignored findings
+// with. Like the disk scanner match it includes the path, so ignoring a component in one location
+// does not hide it in another; detection method and fixed version are left out, since an ignore
+// rule is about the component rather than how it was detected.
+func ignoredLibraryKey(cve, libraryName, path string) matchKey {
+	return matchKey{CVE: cve, DetailedName: libraryName, Path: path}
+}
+
+// newKnownVulnIndex builds the lookup tables in a single pass over the known vulnerabilities.
+// The path comes from the structured locationPath field; the description is only parsed for
+// findings that lack it. Resolved Wiz disk scanner findings are left out of the native tables, so
+// the scanned vulnerability is reported instead. When several known vulnerabilities share a key,
+// the first one wins, except that an unresolved finding replaces a resolved one.
+func newKnownVulnIndex(knownVulns []wizapi.VulnerabilityNode) *knownVulnIndex {
+	index := &knownVulnIndex{
+		nativeLibraries:     make(map[matchKey]indexedVuln),
+		nativeApplications:  make(map[matchKey]indexedVuln),
+		wizcliLibraries:     make(map[matchKey]indexedVuln),
+		wizcliApplications:  make(map[matchKey]indexedVuln),
+		candidates:          make(map[matchKey]indexedVuln),
+		ignoredLibraries:    make(map[matchKey]indexedVuln),
+		ignoredApplications: make(map[matchKey]indexedVuln),
+	}
+
+	for _, kv := range knownVulns {
+		path := kv.LocationPath
+		if path == "" {
+			path, _ = extractPath(kv.Description)
+		}
+		entry := indexedVuln{VulnerabilityNode: kv, path: path}
+
+		// Findings uploaded by wizscan, under any ID scheme, are never taken for native ones
+		uploaded := uploadedByWizscan(kv)
+		if uploaded {
+			addIfAbsent(index.wizcliLibraries, presenceKey(kv.Name, kv.DetailedName, kv.DetectionMethod), entry)
+		} else if kv.DataSourceName == "" && !kv.Resolved() {
+			addIfAbsent(index.nativeLibraries, libraryKey(kv.Name, kv.DetailedName, kv.DetectionMethod, path, kv.FixedVersion), entry)
+		}
+
+		appKey := applicationKey(kv.Name, kv.DetailedName, kv.DetectionMethod, kv.FixedVersion)
+		if uploaded {
+			addIfAbsent(index.wizcliApplications, appKey, entry)
+		} else if !kv.Resolved() {
+			addIfAbsent(index.nativeApplications, appKey, entry)
+		}
+
+		addIfAbsent(index.candidates, candidateKey(kv.Name, kv.DetailedName), entry)
+		if kv.Ignored() && !uploaded {
+			addIfAbsent(index.ignoredLibraries, ignoredLibraryKey(kv.Name, kv.DetailedName, path), entry)
+			addIfAbsent(index.ignoredApplications, candidateKey(kv.Name, kv.DetailedName), entry)
+		}
+	}
+
+	return index
+}
+
+func addIfAbsent(table map[matchKey]indexedVuln, key matchKey, entry indexedVuln) {
+	if existing, exists := table[key]; !exists || (existing.Resolved() && !entry.Resolved()) {
+		table[key] = entry
+	}
+}
+
+// uploadedByWizscan reports whether a known finding came from an earlier wizscan upload.
+func uploadedByWizscan(kv wizapi.VulnerabilityNode) bool {
+	return kv.DataSourceName == "WizCLI" || strings.HasPrefix(kv.ID, "WIZCLI")
+}
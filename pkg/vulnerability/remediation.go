@@ -0,0 +1,130 @@
+package vulnerability
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RemediationGuidance tells how to upgrade a component in its own ecosystem.
+type RemediationGuidance struct {
+	Ecosystem  Ecosystem `json:"ecosystem,omitempty"`
+	Manifest   string    `json:"manifest,omitempty"`   // File declaring the dependency, to edit or regenerate
+	Constraint string    `json:"constraint,omitempty"` // Manifest entry requiring the target, for commands that cannot take it
+	Command    string    `json:"command,omitempty"`    // Command upgrading the component to the target version
+}
+
+// manifestFiles is the file each ecosystem declares its dependencies in
+var manifestFiles = map[Ecosystem]string{
+	EcosystemNpm:   "package.json",
+	EcosystemPip:   "requirements.txt",
+	EcosystemMaven: "pom.xml",
+	EcosystemGo:    "go.mod",
+	EcosystemNuGet: "*.csproj",
+	EcosystemGem:   "Gemfile",
+}
+
+// newRemediationGuidance builds the upgrade guidance for a component. It is empty when there is no
+// target version or the ecosystem has no package manager to upgrade with.
+func newRemediationGuidance(ecosystem Ecosystem, name, componentPath, target string) RemediationGuidance {
+	if target == "" || name == "" {
+		return RemediationGuidance{}
+	}
+	if _, supported := manifestFiles[ecosystem]; !supported {
+		return RemediationGuidance{}
+	}
+	return RemediationGuidance{
+		Ecosystem:  ecosystem,
+		Manifest:   manifestFor(ecosystem, componentPath),
+		Constraint: manifestConstraint(ecosystem, name, target),
+		Command:    upgradeCommand(ecosystem, name, target),
+	}
+}
+
+// manifestFor locates the manifest of the project the component was found in, falling back on the
+// bare manifest name when the path does not point into a project.
+func manifestFor(ecosystem Ecosystem, componentPath string) string {
+	manifest := manifestFiles[ecosystem]
+	p := strings.ReplaceAll(componentPath, "\\", "/")
+	if p == "" {
+		return manifest
+	}
+	base := strings.ToLower(path.Base(p))
+
+	switch ecosystem {
+	case EcosystemNpm:
+		// Installed packages belong to the project above the outermost node_modules
+		if i := strings.Index(p, "/node_modules/"); i >= 0 {
+			return path.Join(p[:i], manifest)
+		}
+		if base == "package-lock.json" || base == "yarn.lock" || base == "pnpm-lock.yaml" || base == "package.json" {
+			return path.Join(path.Dir(p), manifest)
+		}
+	case EcosystemPip:
+		if base == "requirements.txt" || base == "pyproject.toml" {
+			return p
+		}
+		if base == "poetry.lock" {
+			return path.Join(path.Dir(p), "pyproject.toml")
+		}
+		if base == "pipfile.lock" {
+			return path.Join(path.Dir(p), "Pipfile")
+		}
+	case EcosystemMaven, EcosystemGo:
+		if base == "pom.xml" || base == "go.mod" {
+			return p
+		}
+		if base == "go.sum" {
+			return path.Join(path.Dir(p), manifest)
+		}
+	case EcosystemNuGet:
+		if strings.HasSuffix(base, ".csproj") || base == "packages.config" {
+			return p
+		}
+		if base == "packages.lock.json" {
+			return path.Join(path.Dir(p), manifest)
+		}
+	case EcosystemGem:
+		if base == "gemfile.lock" {
+			return path.Join(path.Dir(p), manifest)
+		}
+	}
+	return manifest
+}
+
+// upgradeCommand returns the package manager command upgrading the component to the target version.
+func upgradeCommand(ecosystem Ecosystem, name, target string) string {
+	switch ecosystem {
+	case EcosystemNpm:
+		return fmt.Sprintf("npm install %s@%s", name, target)
+	case EcosystemPip:
+		return fmt.Sprintf("pip install --upgrade \"%s>=%s\"", name, target)
+	case EcosystemMaven:
+		// Only groupId:artifactId coordinates can be targeted by the versions plugin
+		if strings.Count(name, ":") != 1 {
+			return ""
+		}
+		return fmt.Sprintf("mvn versions:use-dep-version -Dincludes=%s -DdepVersion=%s", name, target)
+	case EcosystemGo:
+		if name == "stdlib" {
+			return fmt.Sprintf("go get go@%s", strings.TrimPrefix(strings.TrimPrefix(target, "v"), "go"))
+		}
+		return fmt.Sprintf("go get %s@v%s", name, strings.TrimPrefix(target, "v"))
+	case EcosystemNuGet:
+		return fmt.Sprintf("dotnet add package %s --version %s", name, target)
+	case EcosystemGem:
+		// Bundler takes no target version, so this only works once the Gemfile requires it
+		return fmt.Sprintf("bundle update %s --conservative", name)
+	}
+	return ""
+}
+
+// manifestConstraint returns the manifest entry requiring the target version, for ecosystems whose
+// upgrade command cannot be given the version itself.
+func manifestConstraint(ecosystem Ecosystem, name, target string) string {
+	switch ecosystem {
+	case EcosystemGem:
+		return fmt.Sprintf("gem '%s', '>= %s'", name, target)
+	}
+	return ""
+}
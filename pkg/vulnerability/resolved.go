@@ -0,0 +1,42 @@
+package vulnerability
+
+import (
+	"wizscan/pkg/wizapi"
+)
+
+// FindResolvedVulnerabilities returns the known findings previously uploaded by wizscan whose ID
+// is missing from the asset about to be uploaded. These are the findings the upload closes: the
+// ones remediated on the host since the last upload, but also the ones now left out by a
+// suppression rule, the risk filter or an ignore rule in Wiz. Findings Wiz already shows as
+// resolved are not returned again.
+//
+// Each upload is a full snapshot of the data source: findings that are left out of it are closed
+// by Wiz. Callers therefore have to upload even when there are no findings left to report, as long
+// as this function returns something, otherwise the stale findings stay open.
+func FindResolvedVulnerabilities(asset Asset, knownVulns []wizapi.VulnerabilityNode) []wizapi.VulnerabilityNode {
+	uploaded := make(map[string]bool, len(asset.VulnerabilityFindings))
+	for _, finding := range asset.VulnerabilityFindings {
+		uploaded[finding.Id] = true
+	}
+
+	resolved := make([]wizapi.VulnerabilityNode, 0)
+	for _, kv := range knownVulns {
+		if !uploadedByWizscan(kv) || kv.Resolved() {
+			continue
+		}
+		if !uploaded[kv.ID] {
+			resolved = append(resolved, kv)
+		}
+	}
+
+	return resolved
+}
+
+// presenceKey builds the key used to find the known wizcli finding a scanned vulnerability was uploaded as.
+func presenceKey(cve, component, detectionMethod string) matchKey {
+	return matchKey{
+		CVE:             cve,
+		DetailedName:    component,
+		DetectionMethod: detectionMethod,
+	}
+}
@@ -0,0 +1,124 @@
+package vulnerability
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RiskWeights sets how much each signal contributes to the risk score. Only the ratios between
+// weights matter; the score is always scaled to 0-100.
+type RiskWeights struct {
+	Severity float64 // Normalized vendor severity
+	CVSS     float64 // CVSS base score
+	EPSS     float64 // EPSS probability of exploitation
+	KEV      float64 // Listed in CISA KEV
+	Exploit  float64 // Public exploit available
+	Fix      float64 // A fixed version exists, so the finding is actionable
+}
+
+// DefaultRiskWeights is used for any weight not set explicitly.
+var DefaultRiskWeights = RiskWeights{
+	Severity: 30,
+	CVSS:     20,
+	EPSS:     20,
+	KEV:      15,
+	Exploit:  10,
+	Fix:      5,
+}
+
+// severityRisk maps normalized severities onto the 0-1 range
+var severityRisk = map[string]float64{
+	"Critical": 1,
+	"High":     0.75,
+	"Medium":   0.5,
+	"Low":      0.25,
+	"None":     0,
+}
+
+// ParseRiskWeights reads weights written as comma separated name=value pairs, e.g.
+// "severity=40,epss=30,fix=0". Names not mentioned keep their default weight.
+func ParseRiskWeights(s string) (RiskWeights, error) {
+	weights := DefaultRiskWeights
+	if strings.TrimSpace(s) == "" {
+		return weights, nil
+	}
+
+	fields := map[string]*float64{
+		"severity": &weights.Severity,
+		"cvss":     &weights.CVSS,
+		"epss":     &weights.EPSS,
+		"kev":      &weights.KEV,
+		"exploit":  &weights.Exploit,
+		"fix":      &weights.Fix,
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return RiskWeights{}, fmt.Errorf("invalid risk weight %q, expected name=value", pair)
+		}
+		field, known := fields[strings.ToLower(strings.TrimSpace(name))]
+		if !known {
+			return RiskWeights{}, fmt.Errorf("unknown risk weight %q", name)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return RiskWeights{}, fmt.Errorf("invalid value for risk weight %q: %q", name, value)
+		}
+		*field = weight
+	}
+
+	if weights.total() == 0 {
+		return RiskWeights{}, fmt.Errorf("at least one risk weight must be positive")
+	}
+	return weights, nil
+}
+
+func (w RiskWeights) total() float64 {
+	return w.Severity + w.CVSS + w.EPSS + w.KEV + w.Exploit + w.Fix
+}
+
+// RiskScore rates a finding from 0 to 100 as the weighted average of its signals, each scaled to 0-1.
+func RiskScore(finding VulnerabilityFinding, weights RiskWeights) float64 {
+	total := weights.total()
+	if total == 0 {
+		return 0
+	}
+
+	score := weights.Severity*severityRisk[finding.Severity] +
+		weights.CVSS*math.Min(finding.Intel.CVSSScore/10, 1) +
+		weights.EPSS*finding.Intel.EPSSProbability +
+		weights.KEV*boolRisk(finding.Intel.HasCisaKevExploit) +
+		weights.Exploit*boolRisk(finding.Intel.HasExploit) +
+		weights.Fix*boolRisk(finding.RemediationTarget != "")
+
+	return math.Round(score/total*1000) / 10
+}
+
+// FilterByRiskScore removes the findings scoring below the minimum from the asset and returns them.
+func FilterByRiskScore(asset *Asset, minScore float64) []VulnerabilityFinding {
+	filtered := make([]VulnerabilityFinding, 0)
+	if minScore <= 0 {
+		return filtered
+	}
+
+	kept := asset.VulnerabilityFindings[:0]
+	for _, finding := range asset.VulnerabilityFindings {
+		if finding.RiskScore < minScore {
+			filtered = append(filtered, finding)
+			continue
+		}
+		kept = append(kept, finding)
+	}
+	asset.VulnerabilityFindings = kept
+
+	return filtered
+}
+
+func boolRisk(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package vulnerability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"wizscan/pkg/logger"
+)
+
+// Severities accepted by Wiz, in ascending order
+var allowedSeverities = []string{"None", "Low", "Medium", "High", "Critical"}
+
+// SeverityMap maps lowercase vendor severities onto the severities accepted by Wiz.
+type SeverityMap map[string]string
+
+// DefaultSeverityMap covers the vocabularies of the common vendors and advisory databases.
+var DefaultSeverityMap = SeverityMap{
+	"none":          "None",
+	"informational": "None",
+	"info":          "None",
+	"negligible":    "Low",
+	"unimportant":   "Low",
+	"minor":         "Low",
+	"low":           "Low",
+	"moderate":      "Medium",
+	"medium":        "Medium",
+	"important":     "High",
+	"high":          "High",
+	"major":         "High",
+	"critical":      "Critical",
+	"urgent":        "Critical",
+}
+
+// ParseSeverityMap reads vendor severity mappings written as comma separated vendor=severity
+// pairs, e.g. "Moderate=Medium,Important=High", on top of DefaultSeverityMap.
+func ParseSeverityMap(s string) (SeverityMap, error) {
+	severityMap := make(SeverityMap, len(DefaultSeverityMap))
+	for vendor, severity := range DefaultSeverityMap {
+		severityMap[vendor] = severity
+	}
+	if strings.TrimSpace(s) == "" {
+		return severityMap, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		vendor, severity, found := strings.Cut(strings.TrimSpace(pair), "=")
+		vendor = strings.ToLower(strings.TrimSpace(vendor))
+		if !found || vendor == "" {
+			return nil, fmt.Errorf("invalid severity mapping %q, expected vendor=severity", pair)
+		}
+		normalized, ok := canonicalSeverity(severity)
+		if !ok {
+			return nil, fmt.Errorf("invalid severity %q for %q, expected one of %s", severity, vendor, strings.Join(allowedSeverities, ", "))
+		}
+		severityMap[vendor] = normalized
+	}
+
+	return severityMap, nil
+}
+
+// canonicalSeverity returns the Wiz severity matching s regardless of case.
+func canonicalSeverity(s string) (string, bool) {
+	for _, severity := range allowedSeverities {
+		if strings.EqualFold(strings.TrimSpace(s), severity) {
+			return severity, true
+		}
+	}
+	return "", false
+}
+
+// severityNormalizer maps vendor severities for one comparison and remembers the ones it could not map.
+type severityNormalizer struct {
+	severityMap SeverityMap
+	unmapped    map[string]int
+}
+
+func newSeverityNormalizer(severityMap SeverityMap) *severityNormalizer {
+	if severityMap == nil {
+		severityMap = DefaultSeverityMap
+	}
+	return &severityNormalizer{
+		severityMap: severityMap,
+		unmapped:    make(map[string]int),
+	}
+}
+
+// normalize maps a vendor severity onto a Wiz severity. Missing or unknown severities fall back
+// on the CVSS score, and on "None" when there is no score either.
+func (n *severityNormalizer) normalize(vendorSeverity string, cvssScore float64) string {
+	vendor := strings.ToLower(strings.TrimSpace(vendorSeverity))
+	if vendor != "" {
+		if severity, found := n.severityMap[vendor]; found {
+			return severity
+		}
+		n.unmapped[vendorSeverity]++
+	}
+	return severityFromCVSS(cvssScore)
+}
+
+// warnUnmapped logs the vendor severities that had no mapping, with how often they were seen.
+func (n *severityNormalizer) warnUnmapped() {
+	if len(n.unmapped) == 0 {
+		return
+	}
+	unmapped := make([]string, 0, len(n.unmapped))
+	for severity, count := range n.unmapped {
+		unmapped = append(unmapped, fmt.Sprintf("%q (%d)", severity, count))
+	}
+	sort.Strings(unmapped)
+	logger.Log.Warnf("Unmapped vendor severities, derived from CVSS instead: %s", strings.Join(unmapped, ", "))
+}
+
+// severityFromCVSS buckets a CVSS v3 base score into the qualitative severity scale.
+func severityFromCVSS(score float64) string {
+	switch {
+	case score >= 9:
+		return "Critical"
+	case score >= 7:
+		return "High"
+	case score >= 4:
+		return "Medium"
+	case score > 0:
+		return "Low"
+	default:
+		return "None"
+	}
+}
@@ -0,0 +1,53 @@
+package vulnerability
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ignoredBy names what makes a known finding ignored in Wiz.
+func ignoredBy(kv indexedVuln) string {
+	if ids := kv.IgnoreRuleIDs(); len(ids) > 0 {
+		return "ignore rule " + strings.Join(ids, ", ")
+	}
+	return "status " + kv.Status
+}
+
+// resolvedAt returns " at <time>" when Wiz reported when the finding was resolved.
+func resolvedAt(kv indexedVuln) string {
+	if kv.ResolvedAt == "" {
+		return ""
+	}
+	return " at " + kv.ResolvedAt
+}
+
+// nativeReason explains why a vulnerability the Wiz disk scanner reports is not uploaded.
+func nativeReason(kv indexedVuln) string {
+	if kv.Ignored() {
+		return fmt.Sprintf("Already reported by the Wiz disk scanner and ignored in Wiz by %s", ignoredBy(kv))
+	}
+	return "Already reported by the Wiz disk scanner"
+}
+
+// uploadedVerdict decides on a vulnerability wizscan uploaded before. An ignored finding is
+// uploaded again: left out of the snapshot, Wiz would close it instead of keeping it ignored.
+// A resolved one is reported as new since it came back.
+func uploadedVerdict(kv indexedVuln) (Verdict, string) {
+	switch {
+	case kv.Ignored():
+		return VerdictKeep, fmt.Sprintf("Previously uploaded by wizscan and ignored in Wiz by %s, uploaded so it stays ignored", ignoredBy(kv))
+	case kv.Resolved():
+		return VerdictAdd, fmt.Sprintf("Resolved in Wiz%s but found again", resolvedAt(kv))
+	default:
+		return VerdictKeep, "Previously uploaded by wizscan"
+	}
+}
+
+// newFindingReason explains why a vulnerability wizscan has not uploaded before is added, given
+// the closest known finding if there is one.
+func newFindingReason(candidate indexedVuln, found bool) string {
+	if found && candidate.Resolved() && !uploadedByWizscan(candidate.VulnerabilityNode) {
+		return fmt.Sprintf("Not reported to Wiz yet, the Wiz disk scanner finding was resolved%s", resolvedAt(candidate))
+	}
+	return "Not reported to Wiz yet"
+}
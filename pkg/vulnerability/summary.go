@@ -0,0 +1,58 @@
+package vulnerability
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// severityOrder is the order severities are listed in summaries
+var severityOrder = []string{"Critical", "High", "Medium", "Low", "None"}
+
+// verdictOrder is the order verdicts are listed in summaries
+var verdictOrder = []Verdict{VerdictAdd, VerdictKeep, VerdictIgnore, VerdictSkip, VerdictSuppress, VerdictFilter, VerdictMerge}
+
+// Summary counts the outcome of a comparison.
+type Summary struct {
+	Findings   int             // Findings in the payload
+	BySeverity map[string]int  // Findings in the payload per severity
+	ByVerdict  map[Verdict]int // Scanned vulnerabilities per verdict
+	Resolved   int             // Previously uploaded findings the upload closes
+
+	KnownSource string // Where the known vulnerabilities came from, e.g. the cache and its age
+}
+
+// NewSummary counts the findings of the asset per severity and the decisions per verdict.
+func NewSummary(asset Asset, decisions []Decision, resolved int) Summary {
+	summary := Summary{
+		Findings:   len(asset.VulnerabilityFindings),
+		BySeverity: make(map[string]int),
+		ByVerdict:  make(map[Verdict]int),
+		Resolved:   resolved,
+	}
+	for _, finding := range asset.VulnerabilityFindings {
+		summary.BySeverity[finding.Severity]++
+	}
+	for _, decision := range decisions {
+		summary.ByVerdict[decision.Verdict]++
+	}
+	return summary
+}
+
+// Write prints the summary as a small table.
+func (s Summary) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Findings to upload:\t%d\n", s.Findings)
+	for _, severity := range severityOrder {
+		fmt.Fprintf(tw, "  %s:\t%d\n", severity, s.BySeverity[severity])
+	}
+	fmt.Fprintln(tw, "Scanned vulnerabilities by verdict:\t")
+	for _, verdict := range verdictOrder {
+		fmt.Fprintf(tw, "  %s:\t%d\n", verdict, s.ByVerdict[verdict])
+	}
+	fmt.Fprintf(tw, "Findings closed:\t%d\n", s.Resolved)
+	if s.KnownSource != "" {
+		fmt.Fprintf(tw, "Known vulnerabilities from:\t%s\n", s.KnownSource)
+	}
+	return tw.Flush()
+}
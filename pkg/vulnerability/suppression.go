@@ -0,0 +1,182 @@
+package vulnerability
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"wizscan/pkg/logger"
+)
+
+// suppressionExpiryWarning is how far ahead of its expiry date a rule starts logging warnings
+const suppressionExpiryWarning = 14 * 24 * time.Hour
+
+// SuppressionFile is the on-disk format of a suppression file.
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "cve": "CVE-2021-44228",
+//	      "component": "log4j-core",
+//	      "path": "/opt/legacy/**",
+//	      "asset": "i-0123456789abcdef0",
+//	      "reason": "Isolated host, JNDI lookups disabled",
+//	      "owner": "platform-team@example.com",
+//	      "expires": "2026-12-31"
+//	    }
+//	  ]
+//	}
+type SuppressionFile struct {
+	Rules []SuppressionRule `json:"rules"`
+}
+
+// SuppressionRule accepts the risk of a vulnerability until its expiry date. Component, path and
+// asset are glob patterns (see matchGlob) and match anything when left empty.
+type SuppressionRule struct {
+	CVE       string `json:"cve"`       // CVE the rule applies to, required
+	Component string `json:"component"` // Library or application name
+	Path      string `json:"path"`      // Location of the component on the host
+	Asset     string `json:"asset"`     // Provider ID of the asset
+	Reason    string `json:"reason"`    // Justification for accepting the risk, required
+	Owner     string `json:"owner"`     // Who accepted the risk, required
+	Expires   string `json:"expires"`   // Last day the rule applies, as YYYY-MM-DD, required
+
+	expiresAt time.Time // End of the expiry day
+}
+
+// LoadSuppressionRules reads and validates a suppression file. Malformed and expired rules fail
+// the whole file so that accepted risks are never silently widened or extended. Rules that expire
+// soon are logged as warnings.
+func LoadSuppressionRules(filePath string, now time.Time) ([]SuppressionRule, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppression file: %w", err)
+	}
+
+	// A misspelled key would otherwise be dropped and leave its pattern matching everything
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file SuppressionFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse suppression file: %w", err)
+	}
+	if decoder.More() {
+		return nil, errors.New("failed to parse suppression file: unexpected data after the rules")
+	}
+
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		if err := rule.validate(now); err != nil {
+			return nil, fmt.Errorf("suppression rule %d (%s): %w", i+1, rule.CVE, err)
+		}
+		if remaining := rule.expiresAt.Sub(now); remaining < suppressionExpiryWarning {
+			logger.Log.Warnf("Suppression rule %d for %s (owner: %s) expires on %s", i+1, rule.CVE, rule.Owner, rule.Expires)
+		}
+	}
+
+	logger.Log.Debugf("Loaded %d suppression rules from %s", len(file.Rules), filePath)
+	return file.Rules, nil
+}
+
+// validate checks the rule for missing fields, bad patterns and expiry, and parses its expiry date.
+func (r *SuppressionRule) validate(now time.Time) error {
+	if r.CVE == "" {
+		return errors.New("cve is required")
+	}
+	if r.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if r.Owner == "" {
+		return errors.New("owner is required")
+	}
+	if r.Expires == "" {
+		return errors.New("expires is required")
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", r.Expires, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid expires date %q, expected YYYY-MM-DD", r.Expires)
+	}
+	r.expiresAt = day.AddDate(0, 0, 1)
+	if !now.Before(r.expiresAt) {
+		return fmt.Errorf("expired on %s", r.Expires)
+	}
+
+	for name, pattern := range map[string]string{"component": r.Component, "path": r.Path, "asset": r.Asset} {
+		if _, err := path.Match(strings.TrimSuffix(normalizeFindingPath(pattern), "/**"), ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", name, pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the rule covers the finding on the given asset.
+func (r *SuppressionRule) matches(finding VulnerabilityFinding, providerId string) bool {
+	return strings.EqualFold(r.CVE, finding.Name) &&
+		matchGlob(r.Component, finding.DetailedName) &&
+		matchGlob(r.Path, finding.Path) &&
+		matchGlob(r.Asset, providerId)
+}
+
+// ApplySuppressions removes the findings covered by a suppression rule from the asset, logging
+// each one, and returns the removed findings.
+func ApplySuppressions(asset *Asset, rules []SuppressionRule) []VulnerabilityFinding {
+	suppressed := make([]VulnerabilityFinding, 0)
+	if len(rules) == 0 {
+		return suppressed
+	}
+
+	kept := asset.VulnerabilityFindings[:0]
+	for _, finding := range asset.VulnerabilityFindings {
+		rule := findSuppressionRule(rules, finding, asset.AssetIdentifier.ProviderId)
+		if rule == nil {
+			kept = append(kept, finding)
+			continue
+		}
+		logger.Log.Infof("Suppressed %s in %s (path: %q) until %s: %s (owner: %s)", finding.Name, finding.DetailedName, finding.Path, rule.Expires, rule.Reason, rule.Owner)
+		suppressed = append(suppressed, finding)
+	}
+	asset.VulnerabilityFindings = kept
+
+	return suppressed
+}
+
+func findSuppressionRule(rules []SuppressionRule, finding VulnerabilityFinding, providerId string) *SuppressionRule {
+	for i := range rules {
+		if rules[i].matches(finding, providerId) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// matchGlob matches a value against a path.Match pattern after normalizing both like finding
+// paths. An empty pattern matches everything and a pattern ending in "/**" matches everything
+// below that directory.
+func matchGlob(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	pattern = normalizeFindingPath(pattern)
+	value = normalizeFindingPath(value)
+
+	if prefix, recursive := strings.CutSuffix(pattern, "/**"); recursive {
+		if matched, _ := path.Match(prefix, value); matched {
+			return true
+		}
+		for dir := path.Dir(value); dir != value; value, dir = dir, path.Dir(dir) {
+			if matched, _ := path.Match(prefix, dir); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
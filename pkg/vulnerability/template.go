@@ -0,0 +1,177 @@
+package vulnerability
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// FindingTemplateData is the data model the description and remediation templates are executed
+// against. Custom templates may use any of these fields, e.g. {{.Component}} or {{.Intel.HasExploit}}.
+type FindingTemplateData struct {
+	Kind              string              // Library or Application
+	CVE               string              // Vulnerability identifier, e.g. CVE-2021-44228
+	Component         string              // Library or application name
+	Version           string              // Installed version
+	Path              string              // Location of the component on the host, empty if unknown
+	Locations         []string            // Distinct paths of the duplicates merged into the finding
+	FixedVersion      string              // First version fixing this vulnerability, as reported by wizcli
+	FixVerified       bool                // The installed version was verified to be below FixedVersion
+	Inconsistent      bool                // The installed version looks at or above FixedVersion, so the result may be wrong
+	RemediationTarget string              // Lowest version fixing every advisory on the component, if any
+	Guidance          RemediationGuidance // Ecosystem, manifest, constraint and upgrade command, empty when unknown
+	Severity          string              // Normalized severity sent to Wiz
+	VendorSeverity    string              // Severity as reported by the vendor
+	Link              string              // Advisory URL
+	RiskScore         float64             // Local priority from 0 to 100
+	Intel             ThreatIntel         // Exploitability signals reported by wizcli
+}
+
+// DefaultDescriptionTemplate renders the finding description sent to Wiz.
+const DefaultDescriptionTemplate = `The {{lower .Kind}} ` + "`{{.Component}}`" + ` version ` + "`{{.Version}}`" + `
+{{- if .Path}} located at ` + "`{{.Path}}`" + `{{end}} is vulnerable to ` + "`{{.CVE}}`" + `
+{{- if .FixVerified}}, which exists in versions less than ` + "`{{.FixedVersion}}`" + `{{end}}.
+The vulnerability was found at ` + "`{{.Link}}`" + ` with vendor severity of: ` + "`{{.VendorSeverity}}`" + `.
+{{if .Inconsistent -}}
+Note: the installed version ` + "`{{.Version}}`" + ` does not look below the fixed version ` + "`{{.FixedVersion}}`" + `, so this result may be inconsistent.
+{{end -}}
+{{if .RemediationTarget -}}
+The vulnerability can be remediated by updating the {{lower .Kind}} to version ` + "`{{.RemediationTarget}}`" + ` or higher.
+{{- with .Guidance}}{{if .Constraint}}
+Require ` + "`{{.Constraint}}`" + ` in ` + "`{{.Manifest}}`" + ` and run: ` + "`{{.Command}}`" + `
+{{- else if .Command}}
+Update the dependency in ` + "`{{.Manifest}}`" + ` or run: ` + "`{{.Command}}`" + `
+{{- end}}{{end}}
+{{- else -}}
+At this time there is not a fix for this vulnerability.
+{{- end}}
+{{- if gt (len .Locations) 1}}
+
+Found at:
+{{- range .Locations}}
+- {{.}}
+{{- end}}
+{{- end}}
+
+Threat intelligence:
+- Risk score: {{printf "%.1f" .RiskScore}}/100
+{{- with .Intel}}
+{{- if .CVSSScore}}
+- CVSS score: {{printf "%.1f" .CVSSScore}}{{if .ExploitabilityScore}} (exploitability {{printf "%.1f" .ExploitabilityScore}}){{end}}
+{{- end}}
+- Exploit available: {{yesNo .HasExploit}}
+- CISA KEV: {{yesNo .HasCisaKevExploit}}{{if .CisaKevReleaseDate}} (added {{.CisaKevReleaseDate}}{{if .CisaKevDueDate}}, due {{.CisaKevDueDate}}{{end}}){{end}}
+{{- if .HasEPSS}}
+- EPSS: {{percent .EPSSProbability}} probability, {{percent .EPSSPercentile}} percentile{{if .EPSSSeverity}} ({{.EPSSSeverity}}){{end}}
+{{- end}}
+{{- end}}`
+
+// DefaultRemediationTemplate renders the remediation field sent to Wiz.
+const DefaultRemediationTemplate = `{{if .RemediationTarget -}}
+Upgrade {{.Component}} to {{.RemediationTarget}} or higher.
+{{- with .Guidance}}{{if .Constraint}} Require {{.Constraint}} in {{.Manifest}} and run: {{.Command}}{{else if .Command}} Edit {{.Manifest}} or run: {{.Command}}{{end}}{{end}}
+{{- end}}`
+
+// templateFuncs are the helper functions available to finding templates
+var templateFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"join":    strings.Join,
+	"percent": func(f float64) string { return strconv.FormatFloat(f*100, 'f', 2, 64) + "%" },
+	"yesNo": func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	},
+}
+
+// FindingTemplates holds the parsed description and remediation templates.
+type FindingTemplates struct {
+	Description *template.Template
+	Remediation *template.Template
+}
+
+// LoadFindingTemplates parses the description and remediation templates from the given files,
+// using the default template for any file left empty.
+func LoadFindingTemplates(descriptionFile, remediationFile string) (FindingTemplates, error) {
+	description, err := parseFindingTemplate("description", descriptionFile, DefaultDescriptionTemplate)
+	if err != nil {
+		return FindingTemplates{}, err
+	}
+	remediation, err := parseFindingTemplate("remediation", remediationFile, DefaultRemediationTemplate)
+	if err != nil {
+		return FindingTemplates{}, err
+	}
+	return FindingTemplates{Description: description, Remediation: remediation}, nil
+}
+
+func parseFindingTemplate(name, filePath, fallback string) (*template.Template, error) {
+	text := fallback
+	if filePath != "" {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s template: %w", name, err)
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// newFindingTemplateData collects the template fields of a finding.
+func newFindingTemplateData(finding VulnerabilityFinding) FindingTemplateData {
+	return FindingTemplateData{
+		Kind:              finding.ExternalDetectionSource,
+		CVE:               finding.Name,
+		Component:         finding.DetailedName,
+		Version:           finding.Version,
+		Path:              finding.Path,
+		Locations:         finding.Locations,
+		FixedVersion:      finding.FixedVersion,
+		FixVerified:       finding.FixVerified,
+		Inconsistent:      finding.Inconsistent,
+		RemediationTarget: finding.RemediationTarget,
+		Guidance:          finding.Guidance,
+		Severity:          finding.Severity,
+		VendorSeverity:    finding.VendorSeverity,
+		Link:              finding.ExternalFindingLink,
+		RiskScore:         finding.RiskScore,
+		Intel:             finding.Intel,
+	}
+}
+
+// RenderFindings sets the description and remediation of every finding of the asset from the
+// templates. It runs after deduplication and scoring so the templates see the final data.
+func RenderFindings(asset *Asset, templates FindingTemplates) error {
+	for i := range asset.VulnerabilityFindings {
+		finding := &asset.VulnerabilityFindings[i]
+		data := newFindingTemplateData(*finding)
+
+		description, err := executeFindingTemplate(templates.Description, data)
+		if err != nil {
+			return fmt.Errorf("finding %s: %w", finding.Id, err)
+		}
+		remediation, err := executeFindingTemplate(templates.Remediation, data)
+		if err != nil {
+			return fmt.Errorf("finding %s: %w", finding.Id, err)
+		}
+		finding.Description = description
+		finding.Remediation = remediation
+	}
+	return nil
+}
+
+func executeFindingTemplate(tmpl *template.Template, data FindingTemplateData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
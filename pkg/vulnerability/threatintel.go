@@ -0,0 +1,75 @@
+package vulnerability
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wizscan/pkg/wizcli"
+)
+
+// ThreatIntel holds the exploitability signals wizcli reports for a vulnerability.
+type ThreatIntel struct {
+	CVSSScore           float64 // CVSS base score, 0 when unknown
+	ExploitabilityScore float64 // CVSS exploitability subscore, 0 when unknown
+	HasExploit          bool    // A public exploit is known
+	HasCisaKevExploit   bool    // Listed in the CISA Known Exploited Vulnerabilities catalog
+	CisaKevReleaseDate  string  // Date the vulnerability was added to the KEV catalog
+	CisaKevDueDate      string  // KEV remediation due date
+	EPSSProbability     float64 // EPSS probability of exploitation in the next 30 days, 0 to 1
+	EPSSPercentile      float64 // EPSS percentile, 0 to 1
+	EPSSSeverity        string  // Wiz severity bucket for the EPSS score
+	HasEPSS             bool    // EPSS data is present
+}
+
+// newThreatIntel extracts the signals from a wizcli vulnerability, tolerating the loosely typed fields.
+func newThreatIntel(vuln wizcli.Vulnerability) ThreatIntel {
+	intel := ThreatIntel{
+		CVSSScore:           vuln.Score,
+		ExploitabilityScore: vuln.ExploitabilityScore,
+		HasExploit:          vuln.HasExploit,
+		HasCisaKevExploit:   vuln.HasCisaKevExploit,
+		CisaKevReleaseDate:  dateValue(vuln.CisaKevReleaseDate),
+		CisaKevDueDate:      dateValue(vuln.CisaKevDueDate),
+		EPSSSeverity:        stringValue(vuln.EpssSeverity),
+	}
+	if probability, ok := floatValue(vuln.EpssProbability); ok {
+		intel.EPSSProbability = probability
+		intel.HasEPSS = true
+	}
+	if percentile, ok := floatValue(vuln.EpssPercentile); ok {
+		intel.EPSSPercentile = percentile
+		intel.HasEPSS = true
+	}
+	return intel
+}
+
+// floatValue reads a number that may have been decoded from JSON as a number or a string.
+func floatValue(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// stringValue reads an optional string field.
+func stringValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// dateValue reads an optional timestamp field, keeping only the date.
+func dateValue(v interface{}) string {
+	s := stringValue(v)
+	if i := strings.IndexByte(s, 'T'); i > 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package vulnerability
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxFindingIDLength is the longest finding ID accepted for upload
+const maxFindingIDLength = 256
+
+// maxAnalysisDateSkew is how far in the future an analysis date may be before it is rejected
+const maxAnalysisDateSkew = time.Hour
+
+// uuidPattern matches the integration ID format
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidateIntegrationData checks the enrichment payload for problems Wiz would otherwise only
+// report once the upload is ingested: missing required fields, unknown severities, bad or
+// duplicate finding IDs, unset dates and incomplete asset identifiers. Every problem is reported,
+// each prefixed with the location of the offending element, e.g.
+// dataSources[0].assets[0].vulnerabilityFindings[3] (id=WIZCLI-...).
+func ValidateIntegrationData(data IntegrationData, now time.Time) error {
+	var errs []error
+	fail := func(location, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", location, fmt.Sprintf(format, args...)))
+	}
+
+	if data.IntegrationId == "" {
+		fail("integrationId", "is required")
+	} else if !uuidPattern.MatchString(data.IntegrationId) {
+		fail("integrationId", "%q is not a UUID", data.IntegrationId)
+	}
+	if len(data.DataSources) == 0 {
+		fail("dataSources", "at least one data source is required")
+	}
+
+	for i, dataSource := range data.DataSources {
+		dsLocation := fmt.Sprintf("dataSources[%d]", i)
+		if dataSource.Id == "" {
+			fail(dsLocation+".id", "is required")
+		}
+		if dataSource.AnalysisDate.IsZero() {
+			fail(dsLocation+".analysisDate", "is required")
+		} else if dataSource.AnalysisDate.After(now.Add(maxAnalysisDateSkew)) {
+			fail(dsLocation+".analysisDate", "%s is in the future", dataSource.AnalysisDate.Format(time.RFC3339))
+		}
+
+		// Finding IDs must be unique across the data source
+		seen := make(map[string]string)
+		for j, asset := range dataSource.Assets {
+			assetLocation := fmt.Sprintf("%s.assets[%d]", dsLocation, j)
+			if asset.AssetIdentifier.CloudPlatform == "" {
+				fail(assetLocation+".assetIdentifier.cloudPlatform", "is required")
+			}
+			if asset.AssetIdentifier.ProviderId == "" {
+				fail(assetLocation+".assetIdentifier.providerId", "is required")
+			}
+
+			for k, finding := range asset.VulnerabilityFindings {
+				location := fmt.Sprintf("%s.vulnerabilityFindings[%d] (id=%s)", assetLocation, k, finding.Id)
+				if problem := validateFinding(finding); problem != "" {
+					fail(location, "%s", problem)
+				}
+				if first, duplicate := seen[finding.Id]; duplicate && finding.Id != "" {
+					fail(location, "duplicate id, first used by %s", first)
+				} else {
+					seen[finding.Id] = location
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid enrichment payload: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateFinding returns the problems with a single finding, or an empty string.
+func validateFinding(finding VulnerabilityFinding) string {
+	var problems []string
+	switch {
+	case finding.Id == "":
+		problems = append(problems, "id is required")
+	case len(finding.Id) > maxFindingIDLength:
+		problems = append(problems, fmt.Sprintf("id is %d characters long, the limit is %d", len(finding.Id), maxFindingIDLength))
+	}
+
+	required := []struct{ name, value string }{
+		{"name", finding.Name},
+		{"detailedName", finding.DetailedName},
+		{"externalDetectionSource", finding.ExternalDetectionSource},
+		{"source", finding.Source},
+	}
+	for _, field := range required {
+		if strings.TrimSpace(field.value) == "" {
+			problems = append(problems, field.name+" is required")
+		}
+	}
+
+	if severity, allowed := canonicalSeverity(finding.Severity); !allowed || severity != finding.Severity {
+		problems = append(problems, fmt.Sprintf("severity %q is not one of %s", finding.Severity, strings.Join(allowedSeverities, ", ")))
+	}
+
+	return strings.Join(problems, "; ")
+}
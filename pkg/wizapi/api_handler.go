@@ -2,6 +2,7 @@ package wizapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,35 +13,39 @@ import (
 	"strings"
 	"time"
 	"wizscan/pkg/logger"
+	"wizscan/pkg/utility"
 )
 
 // WizAPI represents the client for interacting with the Wiz API.
 type WizAPI struct {
-	Session        *http.Client
-	WizAPI         map[string]string
-	ClientID       string
-	ClientSecret   string
-	ClientAuthURL  string
-	ClientQueryURL string
-	AuthToken      string // Added field to store the auth token
+	Session         *http.Client
+	ClientID        string
+	ClientSecret    string
+	ClientAuthURL   string
+	ClientQueryURL  string
+	AuthToken       string        // Added field to store the auth token
+	TokenExpiry     time.Time     // When the auth token expires, zero if the server did not say
+	MaxRetries      int           // Retries of a failed query after the first attempt
+	TransferTimeout time.Duration // Limit of each payload upload, 0 for none
 }
 
-// NewWizAPI creates a new instance of WizAPI.
-func NewWizAPI(clientID, clientSecret, clientAuthURL, clientQueryURL string) *WizAPI {
+// tokenRefreshMargin is how long before its expiry the auth token is renewed
+const tokenRefreshMargin = 5 * time.Minute
+
+// NewWizAPI creates a new instance of WizAPI. Each HTTP request, including reading its response,
+// must complete within requestTimeout; 0 means no limit.
+func NewWizAPI(ctx context.Context, clientID, clientSecret, clientAuthURL, clientQueryURL string, requestTimeout time.Duration) *WizAPI {
 	api := &WizAPI{
-		Session: &http.Client{Timeout: 60 * time.Second},
-		WizAPI: map[string]string{
-			"proxy":           "",    // Define if any proxy is used
-			"wiz_req_timeout": "300", // Request timeout in seconds
-		},
+		Session:        utility.NewHTTPClient(requestTimeout),
 		ClientID:       clientID,
 		ClientSecret:   clientSecret,
 		ClientAuthURL:  clientAuthURL,
 		ClientQueryURL: clientQueryURL,
+		MaxRetries:     DefaultMaxRetries,
 	}
 
 	// Authenticate the API Client
-	if err := api.Authenticate(); err != nil {
+	if err := api.Authenticate(ctx); err != nil {
 		logger.Log.Errorf("Failed to authenticate: %v", err)
 		return nil // Exit the program if authentication fails
 	}
@@ -49,7 +54,7 @@ func NewWizAPI(clientID, clientSecret, clientAuthURL, clientQueryURL string) *Wi
 }
 
 // Authenticate authenticates with the WizAPI and stores the auth token
-func (w *WizAPI) Authenticate() error {
+func (w *WizAPI) Authenticate(ctx context.Context) error {
 	// Construct the request data
 	requestData := url.Values{}
 	requestData.Set("audience", "wiz-api")
@@ -58,7 +63,12 @@ func (w *WizAPI) Authenticate() error {
 	requestData.Set("client_secret", w.ClientSecret)
 
 	// Send a POST request to the Wiz API authentication endpoint
-	response, err := w.Session.PostForm(w.ClientAuthURL, requestData)
+	request, err := http.NewRequestWithContext(ctx, "POST", w.ClientAuthURL, strings.NewReader(requestData.Encode()))
+	if err != nil {
+		return fmt.Errorf("error creating authentication request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := w.Session.Do(request)
 	if err != nil {
 		return fmt.Errorf("error authenticating to the Wiz API: %w", err)
 	}
@@ -82,16 +92,47 @@ func (w *WizAPI) Authenticate() error {
 		return errors.New("no access token found in the response")
 	}
 
-	// Store the access token
+	// Store the access token and remember when it expires
 	w.AuthToken = token
+	w.TokenExpiry = time.Time{}
+	if expiresIn, ok := responseData["expires_in"].(float64); ok && expiresIn > 0 {
+		w.TokenExpiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+		logger.Log.Debugf("Auth token expires at %s", w.TokenExpiry.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ensureToken renews the auth token when it is about to expire.
+func (w *WizAPI) ensureToken(ctx context.Context) error {
+	if w.TokenExpiry.IsZero() || time.Now().Add(tokenRefreshMargin).Before(w.TokenExpiry) {
+		return nil
+	}
+	logger.Log.Debug("Auth token is about to expire, re-authenticating")
+	if err := w.Authenticate(ctx); err != nil {
+		return fmt.Errorf("failed to refresh auth token: %w", err)
+	}
 	return nil
 }
 
 // QueryWithRetry attempts to send a GraphQL query and retries if certain conditions are met.
-func (w *WizAPI) QueryWithRetry(query string, variables map[string]interface{}) (*http.Response, error) {
-	// Define how many times you want to retry and the delay between retries
-	maxRetries := 3
-	retryDelay := time.Second * 2
+// The auth token is renewed before it expires, and a query rejected with 401 is replayed once
+// after re-authenticating. Mutation documents are retried as MutateWithRetry does.
+func (w *WizAPI) QueryWithRetry(ctx context.Context, query string, variables interface{}) (*http.Response, error) {
+	return w.send(ctx, query, variables, isMutation(query))
+}
+
+// MutateWithRetry sends a GraphQL operation with side effects, whatever its document type, such
+// as a query that creates an upload. It is only repeated when the earlier attempt certainly did
+// not reach the server or was turned away before being processed.
+func (w *WizAPI) MutateWithRetry(ctx context.Context, query string, variables interface{}) (*http.Response, error) {
+	return w.send(ctx, query, variables, true)
+}
+
+// send posts a GraphQL operation for QueryWithRetry and MutateWithRetry.
+func (w *WizAPI) send(ctx context.Context, query string, variables interface{}, mutation bool) (*http.Response, error) {
+	if err := w.ensureToken(ctx); err != nil {
+		return nil, err
+	}
 
 	// Prepare the request data
 	data := map[string]interface{}{
@@ -106,55 +147,84 @@ func (w *WizAPI) QueryWithRetry(query string, variables map[string]interface{})
 		return nil, err
 	}
 
-	// Create the HTTP request
-	request, err := http.NewRequest("POST", w.ClientQueryURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		log.Printf("Error creating request: %s\n", err)
-		return nil, err
+	response, err := w.sendWithRetry(ctx, jsonData, mutation)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
 	}
 
-	// Set necessary headers
-	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.AuthToken))
-	request.Header.Add("Accept", "application/json")
-	request.Header.Set("Content-Type", "application/json")
+	// The token was revoked or expired early, so get a new one and replay the query once
+	response.Body.Close()
+	logger.Log.Info("Wiz API rejected the auth token, re-authenticating")
+	if err := w.Authenticate(ctx); err != nil {
+		return nil, fmt.Errorf("failed to re-authenticate: %w", err)
+	}
+	return w.sendWithRetry(ctx, jsonData, mutation)
+}
 
-	// Initialize response variable
+// sendWithRetry posts the query with the current auth token, retrying retryable status codes and
+// transient network errors with exponential backoff. A new request is built for every attempt so
+// each one carries the full body. Waiting between attempts stops as soon as the context is done.
+func (w *WizAPI) sendWithRetry(ctx context.Context, jsonData []byte, mutation bool) (*http.Response, error) {
 	var response *http.Response
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		request, reqErr := w.newQueryRequest(ctx, jsonData)
+		if reqErr != nil {
+			return nil, reqErr
+		}
 
-	// Attempt the request with retries
-	for attempt := 0; attempt < maxRetries; attempt++ {
 		response, err = w.Session.Do(request)
 		if err != nil {
-			log.Printf("Error querying Wiz API: %s\n", err)
-			time.Sleep(retryDelay) // Wait before retrying
-			continue               // Proceed to the next attempt
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("error querying Wiz API: %w", ctx.Err())
+			}
+			if attempt >= w.MaxRetries || !retryableNetworkError(err, mutation) {
+				return nil, fmt.Errorf("error querying Wiz API after %d attempts: %w", attempt+1, err)
+			}
+			delay := backoffDelay(attempt)
+			logger.Log.Warnf("Error querying Wiz API: %v, retrying in %s (attempt %d of %d)", err, delay.Round(time.Millisecond), attempt+1, w.MaxRetries)
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
-		// If the status code is not one of the retryable ones, break the loop
-		if !w.RetryableResponseStatusCode(response.StatusCode) {
-			break
+		// If the status code is not one of the retryable ones, the response is final. A mutation is
+		// only repeated when the status says the server did not process it
+		if !w.RetryableResponseStatusCode(response.StatusCode) || (mutation && !statusSafeToRepeat(response.StatusCode)) {
+			return response, nil
 		}
 
 		// Close the previous response body to avoid leaks
-		if response.Body != nil {
-			response.Body.Close()
+		response.Body.Close()
+		if attempt >= w.MaxRetries {
+			return nil, fmt.Errorf("max retries reached with status code: %d", response.StatusCode)
 		}
 
-		log.Printf("Retrying due to status code: %d, attempt: %d\n", response.StatusCode, attempt+1)
-		time.Sleep(retryDelay) // Wait before retrying
+		delay, fromHeader := retryAfterDelay(response, time.Now())
+		if !fromHeader {
+			delay = backoffDelay(attempt)
+		}
+		logger.Log.Warnf("Retrying due to status code: %d in %s (attempt %d of %d)", response.StatusCode, delay.Round(time.Millisecond), attempt+1, w.MaxRetries)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
 	}
+}
 
-	// After the loop, check why the function exited the loop
+// newQueryRequest builds a GraphQL request carrying the current auth token.
+func (w *WizAPI) newQueryRequest(ctx context.Context, jsonData []byte) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, "POST", w.ClientQueryURL, bytes.NewReader(jsonData))
 	if err != nil {
-		// If there was an error in the last attempt, return it
-		return nil, err
-	} else if response != nil && !w.RetryableResponseStatusCode(response.StatusCode) {
-		// If the last response had a non-retryable status code, return the response
-		return response, nil
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	// If none of the above conditions were met, it means all retries were exhausted
-	return nil, fmt.Errorf("max retries reached with status code: %d", response.StatusCode)
+	// Set necessary headers
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.AuthToken))
+	request.Header.Add("Accept", "application/json")
+	request.Header.Set("Content-Type", "application/json")
+	return request, nil
 }
 
 // RetryableResponseStatusCode determines whether a given HTTP status code is retryable
@@ -199,34 +269,45 @@ func RedactAuthToken(output string) string {
 	return redactedOutput
 }
 
-type GraphQLResourceResponse struct {
-	Data struct {
-		GraphSearch struct {
-			MaxCountReached bool `json:"maxCountReached"`
-			TotalCount      int  `json:"totalCount"` // TotalCount is now directly mapped
-			Nodes           []struct {
-				AggregateCount interface{} `json:"aggregateCount"`
-				Entities       []struct {
-					ID           string                 `json:"id"`
-					Name         string                 `json:"name"`
-					Properties   map[string]interface{} `json:"properties"`
-					Technologies []struct {
-						ID   string `json:"id"`
-						Icon string `json:"icon"`
-					} `json:"technologies"`
-					Type         string      `json:"type"`
-					UserMetadata interface{} `json:"userMetadata"`
-				} `json:"entities"`
-			} `json:"nodes"`
-			PageInfo struct {
-				EndCursor   string `json:"endCursor"`
-				HasNextPage bool   `json:"hasNextPage"`
-			} `json:"pageInfo"`
-		} `json:"graphSearch"`
-	} `json:"data"`
-	Errors []GraphQLResourceError `json:"errors"`
+// GraphSearchData is the data of the GraphSearch query.
+type GraphSearchData struct {
+	GraphSearch struct {
+		MaxCountReached bool `json:"maxCountReached"`
+		TotalCount      int  `json:"totalCount"` // TotalCount is now directly mapped
+		Nodes           []struct {
+			AggregateCount interface{} `json:"aggregateCount"`
+			Entities       []struct {
+				ID           string                 `json:"id"`
+				Name         string                 `json:"name"`
+				Properties   map[string]interface{} `json:"properties"`
+				Technologies []struct {
+					ID   string `json:"id"`
+					Icon string `json:"icon"`
+				} `json:"technologies"`
+				Type         string      `json:"type"`
+				UserMetadata interface{} `json:"userMetadata"`
+			} `json:"entities"`
+		} `json:"nodes"`
+		PageInfo struct {
+			EndCursor   string `json:"endCursor"`
+			HasNextPage bool   `json:"hasNextPage"`
+		} `json:"pageInfo"`
+	} `json:"graphSearch"`
 }
 
+// GraphQLResourceError is one entry of the errors of a GraphQL response.
 type GraphQLResourceError struct {
-	Message string `json:"message"`
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"` // Response field the error applies to
+}
+
+func (e GraphQLResourceError) String() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, element := range e.Path {
+		path[i] = fmt.Sprint(element)
+	}
+	return fmt.Sprintf("%s (at %s)", e.Message, strings.Join(path, "."))
 }
@@ -0,0 +1,174 @@
+package wizapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer serves the auth and GraphQL endpoints, answering each GraphQL attempt with the next
+// scripted status and recording the bodies it received.
+type testServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int               // Status of each attempt, 200 once exhausted
+	headers  []map[string]string // Extra headers of each attempt
+	bodies   []string            // GraphQL request bodies in the order received
+	tokens   []string            // Bearer token of each GraphQL request
+	auths    int                 // Authentication requests
+}
+
+func newTestServer(t *testing.T, statuses []int, headers []map[string]string) *testServer {
+	s := &testServer{statuses: statuses, headers: headers}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.auths++
+		token := fmt.Sprintf("token-%d", s.auths)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": token, "expires_in": 3600})
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		attempt := len(s.bodies)
+		s.bodies = append(s.bodies, string(body))
+		s.tokens = append(s.tokens, r.Header.Get("Authorization"))
+		status := http.StatusOK
+		if attempt < len(s.statuses) {
+			status = s.statuses[attempt]
+		}
+		if attempt < len(s.headers) {
+			for name, value := range s.headers[attempt] {
+				w.Header().Set(name, value)
+			}
+		}
+		s.mu.Unlock()
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			io.WriteString(w, `{"data":{"ok":true}}`)
+		}
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) client(t *testing.T) *WizAPI {
+	api := &WizAPI{
+		Session:        s.Server.Client(),
+		ClientID:       "id",
+		ClientSecret:   "secret",
+		ClientAuthURL:  s.URL + "/oauth/token",
+		ClientQueryURL: s.URL + "/graphql",
+		MaxRetries:     DefaultMaxRetries,
+	}
+	if err := api.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	return api
+}
+
+type okData struct {
+	OK bool `json:"ok"`
+}
+
+const testQuery = `query Test($id: ID!) { ok }`
+
+func TestQueryRetriesWithFullBody(t *testing.T) {
+	server := newTestServer(t, []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, []map[string]string{
+		{"Retry-After": "0"},
+		{"Retry-After": "0"},
+	})
+	api := server.client(t)
+
+	data, err := Query[okData](context.Background(), api, testQuery, map[string]interface{}{"id": "abc"})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !data.OK {
+		t.Fatal("Query did not decode the response data")
+	}
+	if len(server.bodies) != 3 {
+		t.Fatalf("server received %d attempts, want 3", len(server.bodies))
+	}
+	for i, body := range server.bodies {
+		if body != server.bodies[0] || body == "" {
+			t.Errorf("attempt %d sent body %q, want %q", i+1, body, server.bodies[0])
+		}
+	}
+}
+
+func TestQueryHonorsRetryAfter(t *testing.T) {
+	server := newTestServer(t, []int{http.StatusTooManyRequests}, []map[string]string{{"Retry-After": "1"}})
+	api := server.client(t)
+
+	start := time.Now()
+	if _, err := Query[okData](context.Background(), api, testQuery, nil); err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %s, want at least the 1s of Retry-After", elapsed)
+	}
+}
+
+func TestQueryReauthenticatesOnce(t *testing.T) {
+	server := newTestServer(t, []int{http.StatusUnauthorized, http.StatusUnauthorized}, nil)
+	api := server.client(t)
+
+	response, err := api.QueryWithRetry(context.Background(), testQuery, nil)
+	if err != nil {
+		t.Fatalf("QueryWithRetry: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want the 401 of the replayed query", response.StatusCode)
+	}
+	if server.auths != 2 {
+		t.Errorf("authenticated %d times, want 2", server.auths)
+	}
+	want := []string{"Bearer token-1", "Bearer token-2"}
+	if len(server.tokens) != len(want) {
+		t.Fatalf("server received %d attempts, want %d", len(server.tokens), len(want))
+	}
+	for i := range want {
+		if server.tokens[i] != want[i] {
+			t.Errorf("attempt %d used %q, want %q", i+1, server.tokens[i], want[i])
+		}
+	}
+}
+
+func TestMutateIsNotRepeatedAfterBadGateway(t *testing.T) {
+	server := newTestServer(t, []int{http.StatusBadGateway}, nil)
+	api := server.client(t)
+
+	_, err := Mutate[okData](context.Background(), api, testQuery, nil)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Mutate = %v, want the 502 as an HTTPError", err)
+	}
+	if len(server.bodies) != 1 {
+		t.Fatalf("server received %d attempts, want 1", len(server.bodies))
+	}
+}
+
+func TestMutateRetriesTooManyRequests(t *testing.T) {
+	server := newTestServer(t, []int{http.StatusTooManyRequests}, []map[string]string{{"Retry-After": "0"}})
+	api := server.client(t)
+
+	if _, err := Mutate[okData](context.Background(), api, testQuery, nil); err != nil {
+		t.Fatalf("Mutate: %v", err)
+	}
+	if len(server.bodies) != 2 {
+		t.Fatalf("server received %d attempts, want 2", len(server.bodies))
+	}
+}
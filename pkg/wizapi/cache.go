@@ -0,0 +1,211 @@
+package wizapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheVersion changes whenever the cached data changes shape, so older entries are ignored
+const cacheVersion = 4
+
+// cachePrefix starts the name of every cache file, so clearing only touches wizscan's files
+const cachePrefix = "known-"
+
+// KnownCache keeps the resolved resource ID and the known vulnerabilities of the host between runs.
+type KnownCache struct {
+	Dir string
+}
+
+// CachedKnown is one cache entry.
+type CachedKnown struct {
+	Version         int                 `json:"version"`
+	Key             string              `json:"key"`
+	ResourceID      string              `json:"resourceId"`
+	ExternalID      string              `json:"externalId,omitempty"` // External ID of the matched resource, which uploads name the host by
+	Vulnerabilities []VulnerabilityNode `json:"vulnerabilities"`
+	FetchedAt       time.Time           `json:"fetchedAt"`
+}
+
+// UploadParts records how many parts the last successful upload of a data source was split into.
+// It is kept apart from the known vulnerabilities, under a key that does not change with how the
+// host was looked up, so a new IP address or search option never loses the count.
+type UploadParts struct {
+	Version    int       `json:"version"`
+	Key        string    `json:"key"`
+	Parts      int       `json:"parts"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// Age returns how long ago the entry was fetched from the Wiz API.
+func (c *CachedKnown) Age(now time.Time) time.Duration {
+	return now.Sub(c.FetchedAt)
+}
+
+// HasVulnerabilities reports whether the entry still holds known vulnerabilities. An upload drops
+// them, since it changes what Wiz knows, and keeps the rest of the entry.
+func (c *CachedKnown) HasVulnerabilities() bool {
+	return !c.FetchedAt.IsZero()
+}
+
+// Invalidate drops the known vulnerabilities of the entry, if any, after an upload changed them in
+// Wiz.
+func (c KnownCache) Invalidate(key string) error {
+	entry, err := c.Load(key)
+	if err != nil || entry == nil {
+		return err
+	}
+	entry.Vulnerabilities = nil
+	entry.FetchedAt = time.Time{}
+	return c.Save(entry)
+}
+
+// DefaultCacheDir returns the wizscan directory in the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the cache directory: %w", err)
+	}
+	return filepath.Join(dir, "wizscan"), nil
+}
+
+// CacheKey identifies what was looked up: the tenant, how the host was searched for and how the
+// known vulnerabilities were filtered. A change to any of them makes earlier entries unused.
+func CacheKey(queryURL string, lookup ResourceLookup, options VulnerabilityQueryOptions) string {
+	options.PageSize = 0 // Page size does not change the result
+	data, _ := json.Marshal(struct {
+		QueryURL string
+		Lookup   ResourceLookup
+		Options  VulnerabilityQueryOptions
+	}{queryURL, lookup, options})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// PartsKey identifies the data source a host is uploaded to, by the data source ID and the
+// provider ID the findings are uploaded for.
+func PartsKey(dataSourceID, providerID string) string {
+	data, _ := json.Marshal(struct {
+		DataSourceID string
+		ProviderID   string
+	}{dataSourceID, providerID})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c KnownCache) path(key string) string {
+	return filepath.Join(c.Dir, cachePrefix+key[:16]+".json")
+}
+
+func (c KnownCache) partsPath(key string) string {
+	return filepath.Join(c.Dir, cachePrefix+"parts-"+key[:16]+".json")
+}
+
+// Load returns the entry stored under the key, or nil when there is none or it was written by
+// another version or for another key.
+func (c KnownCache) Load(key string) (*CachedKnown, error) {
+	var entry CachedKnown
+	if found, err := readCacheFile(c.path(key), &entry); err != nil || !found {
+		return nil, err
+	}
+	if entry.Version != cacheVersion || entry.Key != key {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// Save stores the entry under its key.
+func (c KnownCache) Save(entry *CachedKnown) error {
+	entry.Version = cacheVersion
+	return c.writeCacheFile(c.path(entry.Key), entry)
+}
+
+// LoadParts returns how many parts the last successful upload under the key was split into, or 0
+// when that is unknown.
+func (c KnownCache) LoadParts(key string) (int, error) {
+	var entry UploadParts
+	if found, err := readCacheFile(c.partsPath(key), &entry); err != nil || !found {
+		return 0, err
+	}
+	if entry.Version != cacheVersion || entry.Key != key {
+		return 0, nil
+	}
+	return entry.Parts, nil
+}
+
+// SaveParts records how many parts a successful upload under the key was split into.
+func (c KnownCache) SaveParts(key string, parts int) error {
+	entry := &UploadParts{Version: cacheVersion, Key: key, Parts: parts, UploadedAt: time.Now()}
+	return c.writeCacheFile(c.partsPath(key), entry)
+}
+
+// readCacheFile decodes a cache file into v, returning false when there is none.
+func readCacheFile(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read cache: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse cache: %w", err)
+	}
+	return true, nil
+}
+
+// writeCacheFile encodes v into a cache file. The file is written next to its final name and
+// renamed so an interrupted run never leaves a truncated entry behind.
+func (c KnownCache) writeCacheFile(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(c.Dir, cachePrefix+"*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(file.Name()) // No-op once renamed
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace cache file: %w", err)
+	}
+	return nil
+}
+
+// Clear removes every cache entry, returning how many there were.
+func (c KnownCache) Clear() (int, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), cachePrefix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove cache file: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,176 @@
+package wizapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody is how much of an unexpected HTTP response body is kept in an HTTPError
+const maxErrorBody = 4096
+
+// GraphQLResponse is the envelope of every GraphQL response.
+type GraphQLResponse[T any] struct {
+	Data   T                      `json:"data"`
+	Errors []GraphQLResourceError `json:"errors"`
+}
+
+// HTTPError is returned when the GraphQL endpoint answers with a status other than 200.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       string // Start of the response body
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %s: %s", e.Status, e.Body)
+}
+
+// GraphQLError is returned when the response carries GraphQL errors.
+type GraphQLError struct {
+	Errors []GraphQLResourceError
+}
+
+func (e *GraphQLError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, gqlErr := range e.Errors {
+		messages = append(messages, gqlErr.String())
+	}
+	return "graphql errors: " + strings.Join(messages, "; ")
+}
+
+// DecodeError is returned when the response body is not the expected JSON.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error decoding response: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Query sends a GraphQL query and decodes the data of its response into T. Variables may be a map
+// or any struct that marshals to the variables object.
+func Query[T any](ctx context.Context, client *WizAPI, query string, variables interface{}) (*T, error) {
+	response, err := client.QueryWithRetry(ctx, query, variables)
+	return decodeResponse[T](response, err)
+}
+
+// Mutate is Query for operations with side effects, which are not repeated once they may have
+// been processed (see MutateWithRetry).
+func Mutate[T any](ctx context.Context, client *WizAPI, query string, variables interface{}) (*T, error) {
+	response, err := client.MutateWithRetry(ctx, query, variables)
+	return decodeResponse[T](response, err)
+}
+
+// decodeResponse decodes the data of a GraphQL response into T.
+func decodeResponse[T any](response *http.Response, err error) (*T, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+		return nil, &HTTPError{StatusCode: response.StatusCode, Status: response.Status, Body: string(body)}
+	}
+
+	var envelope GraphQLResponse[T]
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	if len(envelope.Errors) > 0 {
+		return nil, &GraphQLError{Errors: envelope.Errors}
+	}
+	return &envelope.Data, nil
+}
+
+// Connection is one page of a cursor paginated GraphQL field.
+type Connection[N any] struct {
+	Nodes    []N      `json:"nodes"`
+	PageInfo PageInfo `json:"pageInfo"`
+}
+
+// Paginator walks a cursor paginated query page by page:
+//
+//	pages := Paginate(ctx, client, query, variables, connection)
+//	for pages.Next() {
+//		for _, node := range pages.Page() { ... }
+//	}
+//	if err := pages.Err(); err != nil { ... }
+type Paginator[T, N any] struct {
+	ctx        context.Context
+	client     *WizAPI
+	query      string
+	variables  func(after string) interface{}
+	connection func(*T) Connection[N]
+
+	cursor string
+	page   []N
+	pages  int
+	done   bool
+	err    error
+}
+
+// Paginate returns a paginator for the query. The variables function builds the variables for the
+// page after the given cursor, empty for the first page; connection picks the paginated field
+// out of the response data.
+func Paginate[T, N any](ctx context.Context, client *WizAPI, query string, variables func(after string) interface{}, connection func(*T) Connection[N]) *Paginator[T, N] {
+	return &Paginator[T, N]{
+		ctx:        ctx,
+		client:     client,
+		query:      query,
+		variables:  variables,
+		connection: connection,
+	}
+}
+
+// Next fetches the next page, returning false when there are no more pages or a query failed.
+func (p *Paginator[T, N]) Next() bool {
+	if p.done || p.err != nil {
+		return false
+	}
+
+	data, err := Query[T](p.ctx, p.client, p.query, p.variables(p.cursor))
+	if err != nil {
+		p.err = fmt.Errorf("page %d: %w", p.pages+1, err)
+		return false
+	}
+
+	page := p.connection(data)
+	p.pages++
+	p.page = page.Nodes
+	p.cursor = page.PageInfo.EndCursor
+	p.done = !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == ""
+	return true
+}
+
+// Page returns the nodes of the current page.
+func (p *Paginator[T, N]) Page() []N {
+	return p.page
+}
+
+// Pages returns the number of pages fetched so far.
+func (p *Paginator[T, N]) Pages() int {
+	return p.pages
+}
+
+// Err returns the error that stopped the pagination, if any.
+func (p *Paginator[T, N]) Err() error {
+	return p.err
+}
+
+// All fetches the remaining pages and returns their nodes.
+func (p *Paginator[T, N]) All() ([]N, error) {
+	nodes := make([]N, 0)
+	for p.Next() {
+		nodes = append(nodes, p.Page()...)
+	}
+	return nodes, p.Err()
+}
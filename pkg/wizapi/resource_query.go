@@ -1,8 +1,11 @@
 package wizapi
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"wizscan/pkg/logger"
 )
 
 // Define your GraphQL query as a constant
@@ -98,59 +101,195 @@ query GraphSearch($query: GraphEntityQueryInput, $controlId: ID, $projectId: Str
   }
 `
 
-func resourceCreateQueryVariables(scanCloudType, scanProviderID string) map[string]interface{} {
+// Resource match strategies, tried in the configured order until one of them finds the host
+const (
+	MatchExternalID = "externalId" // Provider ID of the instance
+	MatchName       = "name"       // Instance name given with the lookup
+	MatchHostname   = "hostname"   // Host name, full and short, as the entity name
+	MatchPrivateIP  = "privateIp"  // Private IP addresses of the host
+)
+
+// ResourceStrategies are the known match strategies, in their default order
+var ResourceStrategies = []string{MatchExternalID, MatchName, MatchHostname, MatchPrivateIP}
+
+// ErrNoResourceMatch is returned when the search completed but found no entity for the host
+var ErrNoResourceMatch = errors.New("resource not found")
+
+// maxCandidates is how many matching entities a disambiguation report lists
+const maxCandidates = 20
+
+// ResourceLookup describes the scanned host and where to look for it in the Wiz graph.
+type ResourceLookup struct {
+	CloudType  string
+	ProviderID string
+	Name       string   // Instance name, used by the name strategy when set
+	Hostname   string   // Used by the hostname strategy when set
+	PrivateIPs []string // Used by the privateIp strategy when set
+	Types      []string // Entity types searched, e.g. VIRTUAL_MACHINE
+	ProjectID  string   // Project the search is scoped to, * for all projects
+	Strategies []string // Match strategies in the order they are tried
+}
+
+// ResourceCandidate is an entity matching the host.
+type ResourceCandidate struct {
+	ID         string
+	Name       string
+	Type       string
+	ExternalID string
+}
+
+// AmbiguousResourceError is returned when a strategy matches more than one entity, listing them
+// so the search can be narrowed.
+type AmbiguousResourceError struct {
+	Strategy   string
+	TotalCount int
+	Candidates []ResourceCandidate
+}
+
+func (e *AmbiguousResourceError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d resources match by %s:", e.TotalCount, e.Strategy)
+	for _, candidate := range e.Candidates {
+		fmt.Fprintf(&b, "\n  %s  name=%q type=%s externalId=%q", candidate.ID, candidate.Name, candidate.Type, candidate.ExternalID)
+	}
+	if more := e.TotalCount - len(e.Candidates); more > 0 {
+		fmt.Fprintf(&b, "\n  ... and %d more", more)
+	}
+	return b.String()
+}
+
+// matchCondition returns the where condition of a strategy, or nil when the lookup lacks the
+// value the strategy needs.
+func (l ResourceLookup) matchCondition(strategy string) map[string]interface{} {
+	switch strategy {
+	case MatchExternalID:
+		if l.ProviderID != "" {
+			return map[string]interface{}{"externalId": map[string]interface{}{"EQUALS": []string{l.ProviderID}}}
+		}
+	case MatchName:
+		if l.Name != "" {
+			return map[string]interface{}{"name": map[string]interface{}{"EQUALS": []string{l.Name}}}
+		}
+	case MatchHostname:
+		if names := hostnameVariants(l.Hostname); len(names) > 0 {
+			return map[string]interface{}{"name": map[string]interface{}{"EQUALS": names}}
+		}
+	case MatchPrivateIP:
+		if len(l.PrivateIPs) > 0 {
+			return map[string]interface{}{"privateIpAddresses": map[string]interface{}{"EQUALS": l.PrivateIPs}}
+		}
+	}
+	return nil
+}
+
+// hostnameVariants returns the host name as given, lowercase and without its domain, since the
+// entity name is often only the short name.
+func hostnameVariants(hostname string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	add(hostname)
+	add(strings.ToLower(hostname))
+	if short, _, found := strings.Cut(hostname, "."); found {
+		add(short)
+		add(strings.ToLower(short))
+	}
+	return names
+}
+
+func resourceCreateQueryVariables(lookup ResourceLookup, condition map[string]interface{}) map[string]interface{} {
+	where := map[string]interface{}{
+		"cloudPlatform": map[string]interface{}{
+			"EQUALS": []string{lookup.CloudType},
+		},
+	}
+	for field, filter := range condition {
+		where[field] = filter
+	}
 	return map[string]interface{}{
 		"quick": true,
 		"first": 50,
 		"query": map[string]interface{}{
-			"type":   []string{"VIRTUAL_MACHINE"},
+			"type":   lookup.Types,
 			"select": true,
-			"where": map[string]interface{}{
-				"cloudPlatform": map[string]interface{}{
-					"EQUALS": []string{scanCloudType},
-				},
-				"externalId": map[string]interface{}{
-					"EQUALS": []string{scanProviderID},
-				},
-			},
+			"where":  where,
 		},
-		"projectId":       "*",
+		"projectId":       lookup.ProjectID,
 		"fetchTotalCount": true,
 	}
 }
 
-func (w *WizAPI) graphResourceSearch(scanCloudType, scanProviderID string) (*GraphQLResourceResponse, error) {
-	queryVariables := resourceCreateQueryVariables(scanCloudType, scanProviderID)
-	query := ResourceQuery // GraphQL query
-
-	response, err := w.QueryWithRetry(query, queryVariables)
-	if err != nil {
-		return nil, fmt.Errorf("error querying with retry: %w", err)
-	}
+func (w *WizAPI) graphResourceSearch(ctx context.Context, lookup ResourceLookup, condition map[string]interface{}) (*GraphSearchData, error) {
+	queryVariables := resourceCreateQueryVariables(lookup, condition)
+	return Query[GraphSearchData](ctx, w, ResourceQuery, queryVariables)
+}
 
-	// Process the HTTP response and unmarshal the JSON into GraphQLResourceResponse
-	var graphQLResourceResponse GraphQLResourceResponse
-	if err := json.NewDecoder(response.Body).Decode(&graphQLResourceResponse); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
-	}
+// GetResourceID searches the Wiz graph for the scanned host and returns the matching entity, with
+// its resource ID and the external ID an upload must name it by. The strategies are tried in
+// order: the first one matching exactly one entity wins, one matching several fails with an
+// AmbiguousResourceError, and one matching none falls through to the next.
+func (w *WizAPI) GetResourceID(ctx context.Context, lookup ResourceLookup) (*ResourceCandidate, error) {
+	var tried []string
+	for _, strategy := range lookup.Strategies {
+		condition := lookup.matchCondition(strategy)
+		if condition == nil {
+			logger.Log.Debugf("Skipping resource match by %s, no value to match", strategy)
+			continue
+		}
+		tried = append(tried, strategy)
 
-	if len(graphQLResourceResponse.Errors) > 0 {
-		return nil, fmt.Errorf("graphql errors: %v", graphQLResourceResponse.Errors)
-	}
+		graphSearchData, err := w.graphResourceSearch(ctx, lookup, condition)
+		if err != nil {
+			return nil, fmt.Errorf("error executing GraphResourceSearch by %s: %w", strategy, err)
+		}
 
-	return &graphQLResourceResponse, nil
-}
+		candidates := resourceCandidates(graphSearchData)
+		totalCount := graphSearchData.GraphSearch.TotalCount
+		if totalCount < len(candidates) {
+			totalCount = len(candidates)
+		}
+		switch {
+		case totalCount == 0:
+			logger.Log.Debugf("No resource matches by %s", strategy)
+			continue
+		case totalCount > 1:
+			if len(candidates) > maxCandidates {
+				candidates = candidates[:maxCandidates]
+			}
+			return nil, &AmbiguousResourceError{Strategy: strategy, TotalCount: totalCount, Candidates: candidates}
+		case len(candidates) == 0:
+			return nil, fmt.Errorf("resource search by %s counted a match but returned no entity", strategy)
+		}
 
-// GetResourceID executes the GraphQL query and returns the matched resource ID.
-func (w *WizAPI) GetResourceID(cloudType, providerID string) (string, error) {
-	graphQLResourceResponse, err := w.graphResourceSearch(cloudType, providerID)
-	if err != nil {
-		return "", fmt.Errorf("error executing GraphResourceSearch: %w", err)
+		if strategy != MatchExternalID {
+			logger.Log.Infof("Matched resource %s (%s) by %s", candidates[0].ID, candidates[0].Name, strategy)
+		}
+		return &candidates[0], nil
 	}
-	if graphQLResourceResponse.Data.GraphSearch.TotalCount != 1 {
-		return "", fmt.Errorf("found %+v matching External IDs", graphQLResourceResponse.Data.GraphSearch.TotalCount)
+	if len(tried) == 0 {
+		return nil, fmt.Errorf("%w: no match strategy had a value to match", ErrNoResourceMatch)
 	}
+	return nil, fmt.Errorf("%w: no %s resource matches by %s", ErrNoResourceMatch, strings.Join(lookup.Types, " or "), strings.Join(tried, ", "))
+}
 
-	resourceId := graphQLResourceResponse.Data.GraphSearch.Nodes[0].Entities[0].ID
-	return resourceId, nil
+// resourceCandidates lists the entities of a search result.
+func resourceCandidates(data *GraphSearchData) []ResourceCandidate {
+	var candidates []ResourceCandidate
+	for _, node := range data.GraphSearch.Nodes {
+		for _, entity := range node.Entities {
+			externalID, _ := entity.Properties["externalId"].(string)
+			candidates = append(candidates, ResourceCandidate{
+				ID:         entity.ID,
+				Name:       entity.Name,
+				Type:       entity.Type,
+				ExternalID: externalID,
+			})
+		}
+	}
+	return candidates
 }
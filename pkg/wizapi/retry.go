@@ -0,0 +1,110 @@
+package wizapi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxRetries = 3                // Retries after the first attempt unless configured otherwise
+	retryBaseDelay    = time.Second      // Delay before the first retry, doubled on each one
+	retryMaxDelay     = 30 * time.Second // Upper bound of the exponential backoff
+	retryAfterMax     = 2 * time.Minute  // Longest Retry-After the client is willing to honor
+)
+
+// backoffDelay returns the delay before retry number attempt (0 for the first retry): exponential
+// backoff capped at retryMaxDelay, with jitter over its upper half so clients do not retry in step.
+func backoffDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 {
+		delay = retryBaseDelay << attempt
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// statusSafeToRepeat reports whether a retryable status code means the request was turned away
+// before being processed, so a mutation can be sent again. A 502 or 504 can come from a gateway
+// after the server already applied the mutation.
+func statusSafeToRepeat(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
+}
+
+// retryAfterDelay reads the Retry-After header of 429 and 503 responses, given in seconds or as an
+// HTTP date. It returns false when there is no usable header.
+func retryAfterDelay(response *http.Response, now time.Time) (time.Duration, bool) {
+	if response.StatusCode != http.StatusTooManyRequests && response.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := strings.TrimSpace(response.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > retryAfterMax {
+		delay = retryAfterMax
+	}
+	return delay, true
+}
+
+// isMutation reports whether a GraphQL document is a mutation, which must not be sent twice
+// unless it is certain the first attempt never reached the server.
+func isMutation(query string) bool {
+	return strings.HasPrefix(strings.TrimSpace(query), "mutation")
+}
+
+// retryableNetworkError reports whether a failed request can be sent again. Connection failures
+// are always retryable since nothing reached the server; other transient errors, such as timeouts
+// or resets after the request was written, only for operations without side effects.
+func retryableNetworkError(err error, mutation bool) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	if mutation {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.As(err, &opErr) || strings.Contains(err.Error(), "connection reset") || strings.Contains(err.Error(), "EOF")
+}
+
+// sleepContext waits for the delay, returning early with the context's error if it is done first.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
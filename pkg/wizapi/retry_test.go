@@ -0,0 +1,101 @@
+package wizapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	for attempt := 0; attempt < 20; attempt++ {
+		full := retryMaxDelay
+		if attempt < 16 && retryBaseDelay<<attempt < retryMaxDelay {
+			full = retryBaseDelay << attempt
+		}
+		for i := 0; i < 50; i++ {
+			delay := backoffDelay(attempt)
+			if delay < full/2 || delay > full {
+				t.Fatalf("backoffDelay(%d) = %s, want between %s and %s", attempt, delay, full/2, full)
+			}
+		}
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		status int
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"seconds", http.StatusTooManyRequests, "7", 7 * time.Second, true},
+		{"zero", http.StatusServiceUnavailable, "0", 0, true},
+		{"http date", http.StatusServiceUnavailable, now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"negative seconds", http.StatusTooManyRequests, "-5", 0, true},
+		{"date in the past", http.StatusTooManyRequests, now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		{"capped", http.StatusTooManyRequests, "3600", retryAfterMax, true},
+		{"missing", http.StatusTooManyRequests, "", 0, false},
+		{"malformed", http.StatusTooManyRequests, "soon", 0, false},
+		{"bad gateway", http.StatusBadGateway, "7", 0, false},
+		{"gateway timeout", http.StatusGatewayTimeout, "7", 0, false},
+	}
+	for _, c := range cases {
+		response := &http.Response{StatusCode: c.status, Header: http.Header{}}
+		if c.header != "" {
+			response.Header.Set("Retry-After", c.header)
+		}
+		got, ok := retryAfterDelay(response, now)
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s: retryAfterDelay = %s, %v, want %s, %v", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+// timeoutError is a net.Error reporting a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryableNetworkError(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		query    bool // Retryable for a query
+		mutation bool // Retryable for a mutation
+	}{
+		{"dial", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true, true},
+		{"temporary dns", &net.DNSError{Err: "server misbehaving", IsTemporary: true}, true, true},
+		{"dns timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, true, true},
+		{"unknown host", &net.DNSError{Err: "no such host", IsNotFound: true}, false, false},
+		{"timeout", fmt.Errorf("Post: %w", timeoutError{}), true, false},
+		{"read", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true, false},
+		{"connection reset", errors.New("read tcp: connection reset by peer"), true, false},
+		{"eof", errors.New("Post: EOF"), true, false},
+		{"other", errors.New("tls: bad certificate"), false, false},
+	}
+	for _, c := range cases {
+		if got := retryableNetworkError(c.err, false); got != c.query {
+			t.Errorf("%s: retryableNetworkError(query) = %v, want %v", c.name, got, c.query)
+		}
+		if got := retryableNetworkError(c.err, true); got != c.mutation {
+			t.Errorf("%s: retryableNetworkError(mutation) = %v, want %v", c.name, got, c.mutation)
+		}
+	}
+}
+
+func TestSleepContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("sleepContext = %v, want context.Canceled", err)
+	}
+}
@@ -1,7 +1,7 @@
 package wizapi
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -66,43 +66,37 @@ fragment UnresolvedAssetsDetails on EnrichmentIntegrationUnresolvedAssets {
 }
 `
 
-// RequestSecurityScanUploadResponse represents the response structure for the RequestSecurityScanUpload query
-type RequestSecurityScanUploadResponse struct {
-	Data struct {
-		RequestSecurityScanUpload struct {
-			Upload struct {
-				ID               string `json:"id"`
-				URL              string `json:"url"`
-				SystemActivityId string `json:"systemActivityId"`
-			} `json:"upload"`
-		} `json:"requestSecurityScanUpload"`
-	} `json:"data"`
-	Errors []GraphQLResourceError `json:"errors"` // Reuse the existing error struct
+// RequestSecurityScanUploadData is the data of the RequestSecurityScanUpload query
+type RequestSecurityScanUploadData struct {
+	RequestSecurityScanUpload struct {
+		Upload struct {
+			ID               string `json:"id"`
+			URL              string `json:"url"`
+			SystemActivityId string `json:"systemActivityId"`
+		} `json:"upload"`
+	} `json:"requestSecurityScanUpload"`
 }
 
-// SystemActivityResponse is the expected response from the SystemActivity GraphQL query
-type SystemActivityResponse struct {
-	Data struct {
-		SystemActivity struct {
-			ID         string `json:"id"`
-			Status     string `json:"status"`
-			StatusInfo string `json:"statusInfo"`
-			Result     struct {
-				DataSources      IngestionStatsDetails `json:"dataSources"`
-				Findings         IngestionStatsDetails `json:"findings"`
-				Events           IngestionStatsDetails `json:"events"`
-				Tags             IngestionStatsDetails `json:"tags"`
-				UnresolvedAssets struct {
-					Count int      `json:"count"`
-					IDs   []string `json:"ids"`
-				} `json:"unresolvedAssets"`
-			} `json:"result"`
-			Context struct {
-				FileUploadId string `json:"fileUploadId"`
-			} `json:"context"`
-		} `json:"systemActivity"`
-	} `json:"data"`
-	Errors []GraphQLResourceError `json:"errors"`
+// SystemActivityData is the data of the SystemActivity query
+type SystemActivityData struct {
+	SystemActivity struct {
+		ID         string `json:"id"`
+		Status     string `json:"status"`
+		StatusInfo string `json:"statusInfo"`
+		Result     struct {
+			DataSources      IngestionStatsDetails `json:"dataSources"`
+			Findings         IngestionStatsDetails `json:"findings"`
+			Events           IngestionStatsDetails `json:"events"`
+			Tags             IngestionStatsDetails `json:"tags"`
+			UnresolvedAssets struct {
+				Count int      `json:"count"`
+				IDs   []string `json:"ids"`
+			} `json:"unresolvedAssets"`
+		} `json:"result"`
+		Context struct {
+			FileUploadId string `json:"fileUploadId"`
+		} `json:"context"`
+	} `json:"systemActivity"`
 }
 
 type IngestionStatsDetails struct {
@@ -116,76 +110,80 @@ type GraphQLRequest struct {
 	Variables map[string]interface{} `json:"variables"` // Any variables used in the query
 }
 
-// RequestSecurityScanUpload sends a query to request a security scan upload URL and ID for a file
-func (w *WizAPI) requestSecurityScanUpload(filename string) (*RequestSecurityScanUploadResponse, error) {
-	// Prepare the variables for the query
+// RequestSecurityScanUpload sends a query to request a security scan upload URL and ID for a file.
+// Every call creates an upload and a system activity, so it is sent as a mutation.
+func (w *WizAPI) requestSecurityScanUpload(ctx context.Context, filename string) (*RequestSecurityScanUploadData, error) {
 	variables := map[string]interface{}{
 		"filename": filename,
 	}
-
-	// Execute the query using the constant graphFileUploadRequest
-	response, err := w.QueryWithRetry(graphFileUploadRequest, variables)
-	if err != nil {
-		return nil, fmt.Errorf("error querying with retry: %w", err)
-	}
-
-	// Process the HTTP response and unmarshal the JSON into RequestSecurityScanUploadResponse
-	var uploadResponse RequestSecurityScanUploadResponse
-	if err := json.NewDecoder(response.Body).Decode(&uploadResponse); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
-	}
-
-	// Handle any errors in the response
-	if len(uploadResponse.Errors) > 0 {
-		return nil, fmt.Errorf("graphql errors: %v", uploadResponse.Errors)
-	}
-
-	return &uploadResponse, nil
+	return Mutate[RequestSecurityScanUploadData](ctx, w, graphFileUploadRequest, variables)
 }
 
 // querySystemActivity performs the SystemActivity GraphQL query with the given ID.
-func (w *WizAPI) querySystemActivity(systemActivityID string) (*SystemActivityResponse, error) {
-	// Prepare the variables for the query
+func (w *WizAPI) querySystemActivity(ctx context.Context, systemActivityID string) (*SystemActivityData, error) {
 	variables := map[string]interface{}{
 		"id": systemActivityID,
 	}
+	return Query[SystemActivityData](ctx, w, graphSystemActivityQuery, variables)
+}
 
-	// Use QueryWithRetry to perform the query with built-in retry logic
-	response, err := w.QueryWithRetry(graphSystemActivityQuery, variables)
-	if err != nil {
-		return nil, fmt.Errorf("error querying system activity with retry: %w", err)
-	}
-	defer response.Body.Close()
+// UploadResult is the outcome of ingesting one uploaded file, as reported by its system activity.
+type UploadResult struct {
+	SystemActivityID string
+	Status           string
+	StatusInfo       string
+	DataSources      IngestionStatsDetails
+	Findings         IngestionStatsDetails
+	Events           IngestionStatsDetails
+	Tags             IngestionStatsDetails
+	UnresolvedAssets int
+}
 
-	// Decode the response into the SystemActivityResponse struct
-	var systemActivityResponse SystemActivityResponse
-	if err := json.NewDecoder(response.Body).Decode(&systemActivityResponse); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
-	}
+// IngestionTotals adds up the results of several uploads.
+type IngestionTotals struct {
+	Uploads          int
+	ByStatus         map[string]int
+	DataSources      IngestionStatsDetails
+	Findings         IngestionStatsDetails
+	Events           IngestionStatsDetails
+	Tags             IngestionStatsDetails
+	UnresolvedAssets int
+}
 
-	// Check for GraphQL errors
-	if len(systemActivityResponse.Errors) > 0 {
-		return nil, fmt.Errorf("graphql errors: %v", systemActivityResponse.Errors)
+// Add counts an upload result in the totals.
+func (t *IngestionTotals) Add(result *UploadResult) {
+	if t.ByStatus == nil {
+		t.ByStatus = make(map[string]int)
 	}
+	t.Uploads++
+	t.ByStatus[result.Status]++
+	t.DataSources.add(result.DataSources)
+	t.Findings.add(result.Findings)
+	t.Events.add(result.Events)
+	t.Tags.add(result.Tags)
+	t.UnresolvedAssets += result.UnresolvedAssets
+}
 
-	// Return the parsed response
-	return &systemActivityResponse, nil
+func (s *IngestionStatsDetails) add(other IngestionStatsDetails) {
+	s.Incoming += other.Incoming
+	s.Handled += other.Handled
 }
 
 // PublishVulns handles the publication of vulnerability findings by uploading them to an S3 bucket.
-func (w *WizAPI) PublishVulns(tempFilePath string) error {
-	uploadResponse, err := w.requestSecurityScanUpload(tempFilePath)
+// It waits for the ingestion to finish and returns its result.
+func (w *WizAPI) PublishVulns(ctx context.Context, tempFilePath string) (*UploadResult, error) {
+	uploadResponse, err := w.requestSecurityScanUpload(ctx, tempFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to request upload URL: %w", err)
+		return nil, fmt.Errorf("failed to request upload URL: %w", err)
 	}
 
-	uploadURL := uploadResponse.Data.RequestSecurityScanUpload.Upload.URL
+	uploadURL := uploadResponse.RequestSecurityScanUpload.Upload.URL
 	if uploadURL == "" {
-		return fmt.Errorf("received empty upload URL")
+		return nil, fmt.Errorf("received empty upload URL")
 	}
 
-	if err := utility.S3Upload(uploadURL, tempFilePath); err != nil {
-		return fmt.Errorf("failed to upload file to S3: %w", err)
+	if err := utility.S3Upload(ctx, utility.NewHTTPClient(w.TransferTimeout), uploadURL, tempFilePath); err != nil {
+		return nil, fmt.Errorf("failed to upload file to S3: %w", err)
 	}
 
 	logger.Log.Debugln("File successfully uploaded to S3:", uploadURL)
@@ -193,13 +191,16 @@ func (w *WizAPI) PublishVulns(tempFilePath string) error {
 	const maxRetries = 5
 	const retryDelay = 10 // in seconds
 
-	var systemActivityResponse *SystemActivityResponse
+	systemActivityID := uploadResponse.RequestSecurityScanUpload.Upload.SystemActivityId
+	var systemActivityResponse *SystemActivityData
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		systemActivityResponse, err = w.querySystemActivity(uploadResponse.Data.RequestSecurityScanUpload.Upload.SystemActivityId)
+		systemActivityResponse, err = w.querySystemActivity(ctx, systemActivityID)
 		if err != nil {
 			if strings.Contains(err.Error(), "Resource not found") && attempt < maxRetries-1 {
 				logger.Log.Infof("Resource not found, retrying in %d seconds...", retryDelay)
-				time.Sleep(time.Duration(retryDelay) * time.Second)
+				if sleepErr := sleepContext(ctx, time.Duration(retryDelay)*time.Second); sleepErr != nil {
+					return nil, sleepErr
+				}
 				continue
 			} else {
 				logger.Log.Errorf("Error querying system activity: %v", err)
@@ -207,19 +208,31 @@ func (w *WizAPI) PublishVulns(tempFilePath string) error {
 			}
 		}
 
-		if systemActivityResponse.Data.SystemActivity.Status == "IN_PROGRESS" && attempt < maxRetries-1 {
+		if systemActivityResponse.SystemActivity.Status == "IN_PROGRESS" && attempt < maxRetries-1 {
 			logger.Log.Infof("Processing upload, retrying in %d seconds...", retryDelay)
-			time.Sleep(time.Duration(retryDelay) * time.Second)
+			if sleepErr := sleepContext(ctx, time.Duration(retryDelay)*time.Second); sleepErr != nil {
+				return nil, sleepErr
+			}
 			continue
 		}
 		break
 	}
 
-	if err == nil {
-		logger.Log.Infof("System Activity Status: %s", systemActivityResponse.Data.SystemActivity.Status)
-	} else {
+	if err != nil {
 		logger.Log.Error("Failed to query system activity after retries.")
+		return nil, err
 	}
 
-	return err // Return the final state of 'err', whether it's nil or contains an error
+	activity := systemActivityResponse.SystemActivity
+	logger.Log.Infof("System Activity Status: %s", activity.Status)
+	return &UploadResult{
+		SystemActivityID: systemActivityID,
+		Status:           activity.Status,
+		StatusInfo:       activity.StatusInfo,
+		DataSources:      activity.Result.DataSources,
+		Findings:         activity.Result.Findings,
+		Events:           activity.Result.Events,
+		Tags:             activity.Result.Tags,
+		UnresolvedAssets: activity.Result.UnresolvedAssets.Count,
+	}, nil
 }
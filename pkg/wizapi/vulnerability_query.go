@@ -1,12 +1,13 @@
 package wizapi
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"slices"
 	"wizscan/pkg/logger"
 )
 
+// VulnerabilityQuery fetches only the fields the comparison with the scan results reads
 const VulnerabilityQuery = `
 query VulnerabilityFindingsTable($filterBy: VulnerabilityFindingFilters, $first: Int, $after: String) {
 	vulnerabilityFindings(
@@ -20,211 +21,107 @@ query VulnerabilityFindingsTable($filterBy: VulnerabilityFindingFilters, $first:
 		name
 		detailedName
 		description
-		severity: vendorSeverity
-		weightedSeverity
-		status
 		fixedVersion
 		detectionMethod
-		hasExploit
-		hasCisaKevExploit
-		cisaKevReleaseDate
-		cisaKevDueDate
-		firstDetectedAt
-		lastDetectedAt
-		resolvedAt
-		score
-		validatedInRuntime
-		epssSeverity
-		epssPercentile
-		epssProbability
+		locationPath
 		dataSourceName
-		fixDate
-		fixDateBefore
-		publishedDate
-		cvssv2 {
-		  attackVector
-		  attackComplexity
-		  confidentialityImpact
-		  integrityImpact
-		  privilegesRequired
-		  userInteractionRequired
-		}
-		cvssv3 {
-		  attackVector
-		  attackComplexity
-		  confidentialityImpact
-		  integrityImpact
-		  privilegesRequired
-		  userInteractionRequired
-		}
+		status
+		resolvedAt
 		ignoreRules {
 		  id
 		}
-		layerMetadata {
-		  id
-		  details
-		  isBaseLayer
-		}
-		vulnerableAsset {
-		  ... on VulnerableAssetBase {
-			id
-			type
-			name
-			cloudPlatform
-			subscriptionName
-			subscriptionExternalId
-			subscriptionId
-			tags
-			hasLimitedInternetExposure
-			hasWideInternetExposure
-			isAccessibleFromVPN
-			isAccessibleFromOtherVnets
-			isAccessibleFromOtherSubscriptions
-		  }
-		  ... on VulnerableAssetVirtualMachine {
-			id
-			type
-			name
-			cloudPlatform
-			subscriptionName
-			subscriptionExternalId
-			subscriptionId
-			tags
-			operatingSystem
-			imageName
-			imageId
-			imageNativeType
-			hasLimitedInternetExposure
-			hasWideInternetExposure
-			isAccessibleFromVPN
-			isAccessibleFromOtherVnets
-			isAccessibleFromOtherSubscriptions
-		  }
-		  ... on VulnerableAssetServerless {
-			id
-			type
-			name
-			cloudPlatform
-			subscriptionName
-			subscriptionExternalId
-			subscriptionId
-			tags
-			hasLimitedInternetExposure
-			hasWideInternetExposure
-			isAccessibleFromVPN
-			isAccessibleFromOtherVnets
-			isAccessibleFromOtherSubscriptions
-		  }
-		  ... on VulnerableAssetContainerImage {
-			id
-			type
-			name
-			cloudPlatform
-			subscriptionName
-			subscriptionExternalId
-			subscriptionId
-			tags
-			hasLimitedInternetExposure
-			hasWideInternetExposure
-			isAccessibleFromVPN
-			isAccessibleFromOtherVnets
-			isAccessibleFromOtherSubscriptions
-			repository {
-			  vertexId
-			  name
-			}
-			registry {
-			  vertexId
-			  name
-			}
-			scanSource
-			executionControllers {
-			  ...VulnerableAssetExecutionControllerDetails
-			}
-		  }
-		  ... on VulnerableAssetContainer {
-			id
-			type
-			name
-			cloudPlatform
-			subscriptionName
-			subscriptionExternalId
-			subscriptionId
-			tags
-			hasLimitedInternetExposure
-			hasWideInternetExposure
-			isAccessibleFromVPN
-			isAccessibleFromOtherVnets
-			isAccessibleFromOtherSubscriptions
-			executionControllers {
-			  ...VulnerableAssetExecutionControllerDetails
-			}
-		  }
-		}
 	  }
 	  pageInfo {
 		hasNextPage
 		endCursor
 	  }
 	}
-  }
-	  
-	  fragment VulnerableAssetExecutionControllerDetails on VulnerableAssetExecutionController {
-	id
-	entityType
-	externalId
-	providerUniqueId
-	name
-	subscriptionExternalId
-	subscriptionId
-	subscriptionName
-	ancestors {
-	  id
-	  name
-	  entityType
-	  externalId
-	  providerUniqueId
-	}
   }
 `
 
-// GraphQLVar represents the variables for the GraphQL vulnerability query.
-type VulnerabilityVar struct {
-	First           int    `json:"first"`
-	After           string `json:"after,omitempty"` // Added field for pagination cursor
-	FetchTotalCount bool   `json:"fetchTotalCount"` // Added field to control fetching of total count
-	FilterBy        struct {
-		AssetID []string `json:"assetId"`
-	} `json:"filterBy"`
+// DefaultVulnerabilityPageSize is how many findings a page of the vulnerability query holds unless configured otherwise
+const DefaultVulnerabilityPageSize = 100
+
+// VulnerabilityQueryOptions narrows and sizes the vulnerability query.
+type VulnerabilityQueryOptions struct {
+	PageSize    int      // Findings per page, DefaultVulnerabilityPageSize when 0
+	Status      []string // Only findings with one of these statuses, e.g. OPEN; all when empty
+	DataSources []string // Only findings reported by one of these data sources; all when empty
 }
 
-// GraphQLVulnerabilityResponse represents the entire GraphQL response.
-type GraphQLVulnerabilityResponse struct {
-	Data GraphQLVulnerabilityResponseData `json:"data"`
+// VulnerabilityFilter is the filterBy variable of the vulnerability query.
+type VulnerabilityFilter struct {
+	AssetID        []string `json:"assetId"`
+	Status         []string `json:"status,omitempty"`
+	DataSourceName []string `json:"dataSourceName,omitempty"`
 }
 
-// GraphQLVulnerabilityResponseData represents the data structure within the GraphQL response.
-type GraphQLVulnerabilityResponseData struct {
-	VulnerabilityFindings VulnerabilityFindings `json:"vulnerabilityFindings"`
+// GraphQLVar represents the variables for the GraphQL vulnerability query.
+type VulnerabilityVar struct {
+	First           int                 `json:"first"`
+	After           string              `json:"after,omitempty"` // Added field for pagination cursor
+	FetchTotalCount bool                `json:"fetchTotalCount"` // Added field to control fetching of total count
+	FilterBy        VulnerabilityFilter `json:"filterBy"`
 }
 
-// VulnerabilityFindings represents the findings of a vulnerability query.
-type VulnerabilityFindings struct {
-	Nodes    []VulnerabilityNode `json:"nodes"`
-	PageInfo PageInfo            `json:"pageInfo"`
+// VulnerabilityFindingsData is the data of the vulnerability findings query.
+type VulnerabilityFindingsData struct {
+	VulnerabilityFindings Connection[VulnerabilityNode] `json:"vulnerabilityFindings"`
 }
 
 // VulnerabilityNode represents an individual vulnerability.
 type VulnerabilityNode struct {
-	ID              string `json:"id"`
-	Name            string `json:"name"`
-	DetailedName    string `json:"detailedName"`
-	Description     string `json:"description"`
-	FixedVersion    string `json:"fixedVersion"`
-	DetectionMethod string `json:"detectionMethod"`
-	//	Score           string `json:"score"`
-	DataSourceName string `json:"dataSourceName"`
-	PublishedDate  string `json:"publishedDate"`
+	ID              string       `json:"id"`
+	Name            string       `json:"name"`
+	DetailedName    string       `json:"detailedName"`
+	Description     string       `json:"description"`
+	FixedVersion    string       `json:"fixedVersion"`
+	DetectionMethod string       `json:"detectionMethod"`
+	LocationPath    string       `json:"locationPath"`
+	DataSourceName  string       `json:"dataSourceName"`
+	Status          string       `json:"status"`      // One of FindingStatuses
+	ResolvedAt      string       `json:"resolvedAt"`  // When the finding was resolved, if it was
+	IgnoreRules     []IgnoreRule `json:"ignoreRules"` // Wiz ignore rules matching the finding
+}
+
+// IgnoreRule is a Wiz ignore rule applied to a finding.
+type IgnoreRule struct {
+	ID string `json:"id"`
+}
+
+// Statuses of a vulnerability finding in Wiz
+const (
+	FindingStatusOpen     = "OPEN"
+	FindingStatusResolved = "RESOLVED"
+	FindingStatusIgnored  = "IGNORED"
+)
+
+// FindingStatuses are the statuses a vulnerability finding can have, which -knownStatus filters by
+var FindingStatuses = []string{FindingStatusOpen, FindingStatusResolved, FindingStatusIgnored}
+
+// Resolved reports whether Wiz considers the finding fixed.
+func (v VulnerabilityNode) Resolved() bool {
+	return v.Status == FindingStatusResolved
+}
+
+// Ignored reports whether the finding is ignored in Wiz, by an ignore rule or by its status.
+func (v VulnerabilityNode) Ignored() bool {
+	return len(v.IgnoreRules) > 0 || v.Status == FindingStatusIgnored
+}
+
+// SkipsIgnored reports whether the status filter of the options leaves out ignored findings, so
+// the comparison cannot honor them.
+func (o VulnerabilityQueryOptions) SkipsIgnored() bool {
+	return len(o.Status) > 0 && !slices.Contains(o.Status, FindingStatusIgnored)
+}
+
+// IgnoreRuleIDs returns the IDs of the ignore rules matching the finding.
+func (v VulnerabilityNode) IgnoreRuleIDs() []string {
+	ids := make([]string, 0, len(v.IgnoreRules))
+	for _, rule := range v.IgnoreRules {
+		ids = append(ids, rule.ID)
+	}
+	return ids
 }
 
 // PageInfo represents pagination information for GraphQL queries.
@@ -233,14 +130,18 @@ type PageInfo struct {
 	EndCursor   string `json:"endCursor"`
 }
 
-// createVulnerabilityQueryVar creates a VulnerabilityVar for the vulnerability query with the given assetId and pagination cursor.
-func createVulnerabilityQueryVar(assetId string, nextPage string) VulnerabilityVar {
+// createVulnerabilityQueryVar creates a VulnerabilityVar for the vulnerability query with the given assetId, options and pagination cursor.
+func createVulnerabilityQueryVar(assetId string, options VulnerabilityQueryOptions, nextPage string) VulnerabilityVar {
+	pageSize := options.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultVulnerabilityPageSize
+	}
 	variable := VulnerabilityVar{
-		First: 30,
-		FilterBy: struct {
-			AssetID []string `json:"assetId"`
-		}{
-			AssetID: []string{assetId},
+		First: pageSize,
+		FilterBy: VulnerabilityFilter{
+			AssetID:        []string{assetId},
+			Status:         options.Status,
+			DataSourceName: options.DataSources,
 		},
 	}
 
@@ -252,50 +153,21 @@ func createVulnerabilityQueryVar(assetId string, nextPage string) VulnerabilityV
 	return variable
 }
 
-// FetchAllVulnerabilities retrieves all vulnerabilities for a given resource ID.
-func FetchAllVulnerabilities(client *WizAPI, resourceId string) ([]VulnerabilityNode, error) {
-	var allVulnerabilities []VulnerabilityNode // Initialized as an empty slice
-	var endCursor string
-	hasNextPage := true
-	pageCounter := 1
-
-	for hasNextPage {
-		logger.Log.Debug("Fetching vulnerabilities, Page: ", pageCounter)
-
-		variablesStruct := createVulnerabilityQueryVar(resourceId, endCursor)
-
-		var variablesMap map[string]interface{}
-		variablesBytes, err := json.Marshal(variablesStruct)
-		if err != nil {
-			return nil, fmt.Errorf("error marshaling variables: %w", err)
-		}
-		if err := json.Unmarshal(variablesBytes, &variablesMap); err != nil {
-			return nil, fmt.Errorf("error unmarshaling variables: %w", err)
-		}
-
-		httpResponse, err := client.QueryWithRetry(VulnerabilityQuery, variablesMap)
-		if err != nil {
-			return nil, err
-		}
-		defer httpResponse.Body.Close()
-
-		if httpResponse.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("query failed with status code: %d", httpResponse.StatusCode)
-		}
-
-		var tempResponse GraphQLVulnerabilityResponse
-		if err := json.NewDecoder(httpResponse.Body).Decode(&tempResponse); err != nil {
-			return nil, fmt.Errorf("error decoding response: %w", err)
-		}
-
-		// Append the vulnerabilities from this page
-		allVulnerabilities = append(allVulnerabilities, tempResponse.Data.VulnerabilityFindings.Nodes...)
-
-		hasNextPage = tempResponse.Data.VulnerabilityFindings.PageInfo.HasNextPage
-		endCursor = tempResponse.Data.VulnerabilityFindings.PageInfo.EndCursor
-		pageCounter += 1
+// FetchAllVulnerabilities retrieves all vulnerabilities for a given resource ID matching the options.
+func FetchAllVulnerabilities(ctx context.Context, client *WizAPI, resourceId string, options VulnerabilityQueryOptions) ([]VulnerabilityNode, error) {
+	pages := Paginate(ctx, client, VulnerabilityQuery,
+		func(after string) interface{} { return createVulnerabilityQueryVar(resourceId, options, after) },
+		func(data *VulnerabilityFindingsData) Connection[VulnerabilityNode] { return data.VulnerabilityFindings },
+	)
+
+	allVulnerabilities := make([]VulnerabilityNode, 0)
+	for pages.Next() {
+		logger.Log.Debug("Fetched vulnerabilities, Page: ", pages.Pages())
+		allVulnerabilities = append(allVulnerabilities, pages.Page()...)
+	}
+	if err := pages.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching vulnerabilities: %w", err)
 	}
 
-	// If there were no results, allVulnerabilities remains an empty slice
 	return allVulnerabilities, nil
 }
@@ -3,13 +3,13 @@
 package wizcli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"regexp"
-	"runtime"
 	"time"
 	"wizscan/pkg/logger"
 )
@@ -84,7 +84,8 @@ type Vulnerability struct {
 }
 
 // ScanDirectory uses wizcli to scan the specified directory for vulnerabilities and parses the JSON output.
-func ScanDirectory(wizcliPath, directoryPath string) (*ScanOutput, error) {
+// The scan is killed if the context is done first.
+func ScanDirectory(ctx context.Context, wizcliPath, directoryPath string) (*ScanOutput, error) {
 
 	// Get hostname to be used as scan name
 	hostname, err := os.Hostname()
@@ -95,18 +96,16 @@ func ScanDirectory(wizcliPath, directoryPath string) (*ScanOutput, error) {
 
 	scanName := hostname + "-" + directoryPath
 
-	// Construct the command to scan the directory, handling Windows and Unix-like systems appropriately.
-	cmdStr := fmt.Sprintf("%s dir scan --path %s -f json --name %s", wizcliPath, directoryPath, scanName)
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", cmdStr)
-	} else {
-		cmd = exec.Command("sh", "-c", cmdStr)
-	}
+	// Run wizcli directly rather than through a shell, so cancelling the context kills wizcli itself
+	cmd := exec.CommandContext(ctx, wizcliPath, "dir", "scan", "--path", directoryPath, "-f", "json", "--name", scanName)
+	cmd.WaitDelay = killWaitDelay
 
 	// Execute the command and capture its combined output.
 	logger.Log.Debugf("Initiating scan for directory: %s", directoryPath)
 	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("scan of directory %s interrupted: %w", directoryPath, ctx.Err())
+	}
 	if err != nil {
 		// Handle the case where the command execution results in an error not related to parsing.
 		errMsg := err.Error()
@@ -1,6 +1,7 @@
 package wizcli
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -8,9 +9,15 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"time"
 	"wizscan/pkg/logger"
+	"wizscan/pkg/utility"
 )
 
+// killWaitDelay bounds how long a cancelled wizcli command may keep its output open once it was
+// killed, e.g. through a process it started, before wizscan stops waiting for it.
+const killWaitDelay = 10 * time.Second
+
 // WizCliURLs holds the download URLs for wizcli binaries for different platforms and architectures.
 var WizCliURLs = map[string]string{
 	"linux/amd64":   "https://wizcli.app.wiz.io/latest/wizcli-linux-amd64",
@@ -31,8 +38,13 @@ func GetDownloadURL() (string, error) {
 }
 
 // DownloadFile downloads a URL to a local file. It's efficient because it writes as it downloads and doesn't load the whole file into memory.
-func DownloadFile(filepath string, url string) error {
-	resp, err := http.Get(url)
+// The download, including reading the file, must complete within transferTimeout; 0 means no limit.
+func DownloadFile(ctx context.Context, filepath string, url string, transferTimeout time.Duration) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := utility.NewHTTPClient(transferTimeout).Do(req)
 	if err != nil {
 		return err
 	}
@@ -49,7 +61,7 @@ func DownloadFile(filepath string, url string) error {
 }
 
 // SetupEnvironment creates a temporary directory, downloads wizcli, and sets up necessary permissions.
-func SetupEnvironment() (string, error) {
+func SetupEnvironment(ctx context.Context, transferTimeout time.Duration) (string, error) {
 	// Get the correct download URL for the platform
 	url, err := GetDownloadURL()
 	if err != nil {
@@ -70,7 +82,7 @@ func SetupEnvironment() (string, error) {
 
 	// Download the file
 	logger.Log.Debugf("Downloading wizcli: %v", downloadPath)
-	if err := DownloadFile(downloadPath, url); err != nil {
+	if err := DownloadFile(ctx, downloadPath, url, transferTimeout); err != nil {
 		os.RemoveAll(tmpDir) // Clean up the temporary directory
 		return "", fmt.Errorf("error downloading wizcli: %v", err)
 	}
@@ -87,8 +99,9 @@ func SetupEnvironment() (string, error) {
 	return downloadPath, nil
 }
 
-func AuthenticateWizcli(wizcliPath, wizClientID, wizClientSecret string) (string, error) {
-	cmd := exec.Command(wizcliPath, "auth", "--id", wizClientID, "--secret", wizClientSecret)
+func AuthenticateWizcli(ctx context.Context, wizcliPath, wizClientID, wizClientSecret string) (string, error) {
+	cmd := exec.CommandContext(ctx, wizcliPath, "auth", "--id", wizClientID, "--secret", wizClientSecret)
+	cmd.WaitDelay = killWaitDelay
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("wizcli authentication failed: %v - Output: %s", err, string(output))
@@ -112,8 +125,9 @@ func CleanupEnvironment(downloadPath string) error {
 
 // InitializeAndAuthenticate sets up the environment for wizcli, downloads it if necessary,
 // authenticates using the provided credentials, and returns the path to the wizcli executable.
-func InitializeAndAuthenticate(clientID, clientSecret string) (cleanupFunc func(), wizCliPath string, err error) {
-	wizCliPath, err = SetupEnvironment()
+// The download must complete within transferTimeout; 0 means no limit.
+func InitializeAndAuthenticate(ctx context.Context, clientID, clientSecret string, transferTimeout time.Duration) (cleanupFunc func(), wizCliPath string, err error) {
+	wizCliPath, err = SetupEnvironment(ctx, transferTimeout)
 	if err != nil {
 		logger.Log.Errorf("Failed to set up wizcli environment: %v", err)
 		return nil, "", err // Adjusted to return an empty string for the path in case of error
@@ -134,7 +148,7 @@ func InitializeAndAuthenticate(clientID, clientSecret string) (cleanupFunc func(
 	}
 
 	// Authenticate wizcli
-	authMessage, err := AuthenticateWizcli(wizCliPath, clientID, clientSecret)
+	authMessage, err := AuthenticateWizcli(ctx, wizCliPath, clientID, clientSecret)
 	if err != nil {
 		cleanupFunc()
 		logger.Log.Errorf("Failed to authenticate wizcli: %v", err)